@@ -0,0 +1,132 @@
+package em
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/ofeefo/em/internal/instrument"
+)
+
+// Catalog lists the metrics recorded through an instruments struct, as
+// declared by its tags.
+type Catalog struct {
+	// Type is the package-qualified name of the struct.
+	Type    string   `json:"type" yaml:"type"`
+	Metrics []Metric `json:"metrics" yaml:"metrics"`
+}
+
+// Metric describes an instrument field of a Catalog. Fields of the elements
+// of arrays and slices are described once for all of them.
+type Metric struct {
+	// Path is the dotted path of the field, where elements are denoted by
+	// [], as in Workers[].Processed.
+	Path string `json:"path" yaml:"path"`
+	// ID is the instrument id, including prefixes.
+	ID string `json:"id" yaml:"id"`
+	// Type is the em instrument type, such as I64Counter or Timer.
+	Type string `json:"type" yaml:"type"`
+	// Kind is one of counter, updown, gauge or histogram.
+	Kind string `json:"kind" yaml:"kind"`
+	// Number is the number type of measurements, int64 or float64.
+	Number      string `json:"number" yaml:"number"`
+	Observable  bool   `json:"observable,omitempty" yaml:"observable,omitempty"`
+	Unit        string `json:"unit,omitempty" yaml:"unit,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Buckets are the explicit bucket boundaries of histograms, if set.
+	Buckets []float64 `json:"buckets,omitempty" yaml:"buckets,omitempty"`
+	// Exponential is the configuration of histograms using the base2
+	// exponential aggregation.
+	Exponential *Exponential `json:"exponential,omitempty" yaml:"exponential,omitempty"`
+	// Attributes are the static attributes of the instrument.
+	Attributes map[string]string `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+	// Key is the attribute holding the keys of families.
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+	// Indexes are the attributes holding the indexes of the elements of the
+	// arrays and slices enclosing the field, outermost first.
+	Indexes []string `json:"indexes,omitempty" yaml:"indexes,omitempty"`
+	// PrometheusName is the name of the metric exported by the Prometheus
+	// exporter.
+	PrometheusName string `json:"prometheusName" yaml:"prometheusName"`
+}
+
+// Describe is like DescribeIn, using the package-level registry.
+func Describe[T any](attrs ...attribute.KeyValue) (Catalog, error) {
+	return DescribeIn[T](defaultRegistry, attrs...)
+}
+
+// DescribeIn returns the catalog of the metrics InitIn would initialize for
+// T, with attrs, without initializing them. It fails as InitIn would on
+// misconfigured fields.
+func DescribeIn[T any](r *Registry, attrs ...attribute.KeyValue) (Catalog, error) {
+	s := new(T)
+	found, st, err := describeRef(r, s, attrs...)
+	if err != nil {
+		return Catalog{}, err
+	}
+	if err = st.errs.err(); err != nil {
+		return Catalog{}, err
+	}
+
+	c := Catalog{Type: reflect.TypeOf(s).Elem().String()}
+	seen := map[string]bool{}
+	for _, d := range found {
+		path := elementIndex.ReplaceAllString(d.path, "[]")
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		info := instrument.Types[d.typeName]
+		m := Metric{
+			Path:           path,
+			ID:             d.spec.ID,
+			Type:           d.typeName,
+			Kind:           info.Kind,
+			Number:         "float64",
+			Observable:     info.Observable,
+			Unit:           d.spec.Unit,
+			Description:    d.spec.Description,
+			Exponential:    d.spec.Exponential,
+			Key:            d.key,
+			Indexes:        d.indexes,
+			PrometheusName: prometheusName(d.spec.ID, d.typeName, d.spec.Unit),
+		}
+		if strings.HasPrefix(d.typeName, i64Type) {
+			m.Number = "int64"
+		}
+		if len(d.spec.Buckets) > 0 {
+			m.Buckets = d.spec.Buckets
+		}
+		if len(d.attrs) > 0 {
+			m.Attributes = make(map[string]string, len(d.attrs))
+			for _, a := range d.attrs {
+				m.Attributes[string(a.Key)] = a.Value.Emit()
+			}
+		}
+		c.Metrics = append(c.Metrics, m)
+	}
+	return c, nil
+}
+
+// elementIndex matches the indexes of elements in field paths.
+var elementIndex = regexp.MustCompile(`\[\d+\]`)
+
+// describeRef walks the struct base points to as InitIn would, returning the
+// instruments found instead of initializing them, along with the state
+// holding the errors of its fields.
+func describeRef(r *Registry, base any, attrs ...attribute.KeyValue) ([]described, *initState, error) {
+	if kind := reflect.TypeOf(base).Elem().Kind(); kind != reflect.Struct {
+		return nil, nil, fmt.Errorf("expected a struct type, got %s", kind.String())
+	}
+
+	var found []described
+	st := &initState{describe: func(d described) {
+		found = append(found, d)
+	}}
+	initRef(r, base, r.Namespace(), "", st, attrs...)
+	return found, st, nil
+}
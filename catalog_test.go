@@ -0,0 +1,80 @@
+package em
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestDescribe(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Does describe instrument fields", func(t *testing.T) {
+		type instruments struct {
+			Requests I64Counter                 `id:"requests" desc:"Requests served"`
+			Latency  F64Histogram               `id:"latency" unit:"ms" buckets:"1,2"`
+			Duration Timer                      `id:"duration"`
+			Sizes    F64Histogram               `id:"sizes" unit:"By" aggregation:"exponential" maxsize:"160" maxscale:"20"`
+			PerRoute Family[string, I64Counter] `id:"routes" key:"route"`
+			Workers  [2]struct {
+				Busy I64Gauge `id:"busy"`
+			} `prefix:"worker_" index:"worker" attrs:"pool,main"`
+		}
+		c, err := DescribeIn[instruments](New(WithNamespace("api.")), attribute.Bool("test", true))
+		require.NoError(t, err)
+		require.Equal(t, "em.instruments", c.Type)
+
+		static := map[string]string{"test": "true"}
+		require.Equal(t, []Metric{
+			{Path: "Requests", ID: "api.requests", Type: "I64Counter", Kind: "counter", Number: "int64", Description: "Requests served", Attributes: static, PrometheusName: "api_requests_total"},
+			{Path: "Latency", ID: "api.latency", Type: "F64Histogram", Kind: "histogram", Number: "float64", Unit: "ms", Buckets: []float64{1, 2}, Attributes: static, PrometheusName: "api_latency_milliseconds"},
+			{Path: "Duration", ID: "api.duration", Type: "Timer", Kind: "histogram", Number: "float64", Unit: "s", Buckets: defaultSecondsBuckets, Attributes: static, PrometheusName: "api_duration_seconds"},
+			{Path: "Sizes", ID: "api.sizes", Type: "F64Histogram", Kind: "histogram", Number: "float64", Unit: "By", Exponential: &Exponential{MaxSize: 160, MaxScale: 20}, Attributes: static, PrometheusName: "api_sizes_bytes"},
+			{Path: "PerRoute", ID: "api.routes", Type: "I64Counter", Kind: "counter", Number: "int64", Attributes: static, Key: "route", PrometheusName: "api_routes_total"},
+			{Path: "Workers[].Busy", ID: "api.worker_busy", Type: "I64Gauge", Kind: "gauge", Number: "int64", Attributes: map[string]string{"test": "true", "pool": "main"}, Indexes: []string{"worker"}, PrometheusName: "api_worker_busy"},
+		}, c.Metrics)
+	})
+
+	t.Run("Does describe elements once regardless of their number", func(t *testing.T) {
+		type shard struct {
+			Replicas []struct {
+				Lag I64Gauge `id:"lag"`
+			} `len:"2" index:"replica"`
+		}
+		type few struct {
+			Shards []shard `len:"2" index:"shard"`
+		}
+		type many struct {
+			Shards []shard `len:"5" index:"shard"`
+		}
+
+		fewCatalog, err := DescribeIn[few](New())
+		require.NoError(t, err)
+		manyCatalog, err := DescribeIn[many](New())
+		require.NoError(t, err)
+		require.Equal(t, []Metric{
+			{Path: "Shards[].Replicas[].Lag", ID: "lag", Type: "I64Gauge", Kind: "gauge", Number: "int64", Indexes: []string{"shard", "replica"}, PrometheusName: "lag"},
+		}, fewCatalog.Metrics)
+		require.Equal(t, fewCatalog.Metrics, manyCatalog.Metrics)
+	})
+
+	t.Run("Does not register instruments", func(t *testing.T) {
+		r := New(WithStrictIDs())
+		_, err := DescribeIn[ordersCounter](r)
+		require.NoError(t, err)
+		require.Empty(t, r.ids)
+	})
+
+	t.Run("Fails with misconfigured fields", func(t *testing.T) {
+		_, err := DescribeIn[misconfigured](New())
+		var initErr *InitError
+		require.ErrorAs(t, err, &initErr)
+		require.Len(t, initErr.Fields, 9)
+	})
+
+	t.Run("Fails with non-struct types", func(t *testing.T) {
+		_, err := Describe[int]()
+		require.ErrorContains(t, err, "expected a struct type, got int")
+	})
+}
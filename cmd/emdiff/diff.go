@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ofeefo/em"
+)
+
+// readCatalogs reads the catalogs of name, a file or a rev:path git object.
+func readCatalogs(name string) ([]em.Catalog, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		rev, path, ok := strings.Cut(name, ":")
+		if !os.IsNotExist(err) || !ok || rev == "" {
+			return nil, err
+		}
+		if b, err = gitShow(rev, path); err != nil {
+			return nil, err
+		}
+		name = path
+	}
+
+	var catalogs []em.Catalog
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &catalogs)
+	default:
+		err = json.Unmarshal(b, &catalogs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed reading catalogs of %s: %s", name, err)
+	}
+	return catalogs, nil
+}
+
+func gitShow(rev, path string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "show", rev+":"+path)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git show %s:%s failed: %s\n%s", rev, path, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// change is a breaking change of a metric.
+type change struct {
+	// typ and path locate the field of the metric in the old catalogs.
+	typ, path string
+	id        string
+	msg       string
+}
+
+func (c change) String() string {
+	return fmt.Sprintf("%s.%s (id %s): %s", c.typ, c.path, c.id, c.msg)
+}
+
+// entry is a metric along with the type of its catalog.
+type entry struct {
+	typ string
+	em.Metric
+}
+
+func (e entry) field() string {
+	return e.typ + "." + e.Path
+}
+
+// diff returns the breaking changes from the metrics of old to those of
+// updated.
+//
+// Metrics are matched by field, reporting id changes as renames. Metrics
+// whose field is gone are matched by id to a field that is new in updated,
+// for moved fields to be compared too.
+func diff(old, updated []em.Catalog) []change {
+	oldFields := map[string]bool{}
+	for _, e := range entries(old) {
+		oldFields[e.field()] = true
+	}
+	byField := map[string]entry{}
+	added := map[string][]entry{}
+	for _, e := range entries(updated) {
+		byField[e.field()] = e
+		if !oldFields[e.field()] {
+			added[e.ID] = append(added[e.ID], e)
+		}
+	}
+
+	var changes []change
+	for _, o := range entries(old) {
+		report := func(format string, args ...any) {
+			changes = append(changes, change{typ: o.typ, path: o.Path, id: o.ID, msg: fmt.Sprintf(format, args...)})
+		}
+
+		n, ok := byField[o.field()]
+		if !ok {
+			if len(added[o.ID]) == 0 {
+				report("metric removed")
+				continue
+			}
+			n, added[o.ID] = added[o.ID][0], added[o.ID][1:]
+		}
+		compare(o, n, report)
+	}
+	return changes
+}
+
+// compare reports the breaking changes from o to n, which are those making
+// registries report conflicting registrations, along with renamed ids and
+// dropped or changed attributes, including family keys and element indexes.
+func compare(o, n entry, report func(format string, args ...any)) {
+	if o.ID != n.ID {
+		report("id renamed to %s", n.ID)
+	}
+	if o.Kind != n.Kind {
+		report("kind changed from %s to %s", o.Kind, n.Kind)
+	}
+	if o.Number != n.Number {
+		report("number type changed from %s to %s", o.Number, n.Number)
+	}
+	if o.Unit != n.Unit {
+		report("unit changed from %q to %q", o.Unit, n.Unit)
+	}
+	if before, after := aggregation(o), aggregation(n); before != after {
+		report("buckets changed from %s to %s", before, after)
+	}
+
+	keys := make([]string, 0, len(o.Attributes))
+	for k := range o.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, ok := n.Attributes[k]
+		switch {
+		case !ok:
+			report("attribute %s dropped", k)
+		case v != o.Attributes[k]:
+			report("attribute %s changed from %q to %q", k, o.Attributes[k], v)
+		}
+	}
+	for i, index := range o.Indexes {
+		switch {
+		case i >= len(n.Indexes):
+			report("index attribute %s dropped", index)
+		case index != n.Indexes[i]:
+			report("index attribute renamed from %s to %s", index, n.Indexes[i])
+		}
+	}
+	if o.Key != "" && o.Key != n.Key {
+		if n.Key == "" {
+			report("family key %s dropped", o.Key)
+		} else {
+			report("family key renamed from %s to %s", o.Key, n.Key)
+		}
+	}
+}
+
+// aggregation describes the bucket layout of histograms.
+func aggregation(m entry) string {
+	switch {
+	case m.Exponential != nil:
+		return fmt.Sprintf("exponential (maxsize %d, maxscale %d)", m.Exponential.MaxSize, m.Exponential.MaxScale)
+	case len(m.Buckets) > 0:
+		return fmt.Sprintf("%v", m.Buckets)
+	}
+	return "defaults"
+}
+
+func entries(catalogs []em.Catalog) []entry {
+	var all []entry
+	for _, c := range catalogs {
+		for _, m := range c.Metrics {
+			all = append(all, entry{typ: c.Type, Metric: m})
+		}
+	}
+	return all
+}
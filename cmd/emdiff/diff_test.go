@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ofeefo/em"
+)
+
+func TestDiff(t *testing.T) {
+	latency := em.Metric{Path: "Latency", ID: "latency", Type: "F64Histogram", Kind: "histogram", Number: "float64", Unit: "ms", Buckets: []float64{1, 2}}
+	routes := em.Metric{Path: "PerRoute", ID: "routes", Type: "I64Counter", Kind: "counter", Number: "int64", Key: "route", Attributes: map[string]string{"service": "api"}}
+	catalogs := func(metrics ...em.Metric) []em.Catalog {
+		return []em.Catalog{{Type: "x.s", Metrics: metrics}}
+	}
+
+	t.Run("Does accept identical and compatible catalogs", func(t *testing.T) {
+		require.Empty(t, diff(catalogs(latency, routes), catalogs(latency, routes)))
+
+		timer := latency
+		timer.Type, timer.Description = "Timer", "Latency"
+		extra := routes
+		extra.Attributes = map[string]string{"service": "api", "region": "eu"}
+		added := em.Metric{Path: "Added", ID: "added", Type: "I64Gauge", Kind: "gauge", Number: "int64"}
+		require.Empty(t, diff(catalogs(latency, routes), catalogs(timer, extra, added)))
+	})
+
+	t.Run("Does match moved fields by id", func(t *testing.T) {
+		moved := latency
+		moved.Path = "HTTP.Latency"
+		moved.Buckets = []float64{1, 3}
+		require.Equal(t, []string{"x.s.Latency (id latency): buckets changed from [1 2] to [1 3]"}, messages(diff(catalogs(latency), catalogs(moved))))
+	})
+
+	changed := map[string]struct {
+		update func(m *em.Metric)
+		want   string
+	}{
+		"removed metrics": {
+			update: func(m *em.Metric) { m.Path, m.ID = "Other", "other" },
+			want:   "metric removed",
+		},
+		"renamed ids": {
+			update: func(m *em.Metric) { m.ID = "requests" },
+			want:   "id renamed to requests",
+		},
+		"kind changes": {
+			update: func(m *em.Metric) { m.Type, m.Kind = "I64Gauge", "gauge" },
+			want:   "kind changed from counter to gauge",
+		},
+		"number type changes": {
+			update: func(m *em.Metric) { m.Type, m.Number = "F64Counter", "float64" },
+			want:   "number type changed from int64 to float64",
+		},
+		"unit changes": {
+			update: func(m *em.Metric) { m.Unit = "{request}" },
+			want:   `unit changed from "" to "{request}"`,
+		},
+		"bucket changes": {
+			update: func(m *em.Metric) { m.Exponential = &em.Exponential{MaxSize: 160, MaxScale: 20} },
+			want:   "buckets changed from defaults to exponential (maxsize 160, maxscale 20)",
+		},
+		"dropped attributes": {
+			update: func(m *em.Metric) { m.Attributes = nil },
+			want:   "attribute service dropped",
+		},
+		"changed attributes": {
+			update: func(m *em.Metric) { m.Attributes = map[string]string{"service": "web"} },
+			want:   `attribute service changed from "api" to "web"`,
+		},
+		"renamed family keys": {
+			update: func(m *em.Metric) { m.Key = "path" },
+			want:   "family key renamed from route to path",
+		},
+	}
+	for name, c := range changed {
+		t.Run("Does report "+name, func(t *testing.T) {
+			updated := routes
+			c.update(&updated)
+			require.Equal(t, []string{"x.s.PerRoute (id routes): " + c.want}, messages(diff(catalogs(routes), catalogs(updated))))
+		})
+	}
+
+	t.Run("Does accept changes of the number of elements", func(t *testing.T) {
+		type worker struct {
+			Busy em.I64Gauge `id:"busy"`
+		}
+		few, err := em.DescribeIn[struct {
+			Workers [2]worker `index:"worker"`
+		}](em.New())
+		require.NoError(t, err)
+		many, err := em.DescribeIn[struct {
+			Workers [8]worker `index:"worker"`
+		}](em.New())
+		require.NoError(t, err)
+
+		few.Type, many.Type = "x.s", "x.s"
+		require.Empty(t, diff([]em.Catalog{few}, []em.Catalog{many}))
+	})
+
+	t.Run("Does report changed indexes", func(t *testing.T) {
+		busy := em.Metric{Path: "Workers[].Busy", ID: "busy", Type: "I64Gauge", Kind: "gauge", Number: "int64", Indexes: []string{"worker"}}
+		renamed := busy
+		renamed.Indexes = []string{"index"}
+		require.Equal(t, []string{"x.s.Workers[].Busy (id busy): index attribute renamed from worker to index"}, messages(diff(catalogs(busy), catalogs(renamed))))
+
+		single := busy
+		single.Path, single.Indexes = "Busy", nil
+		require.Equal(t, []string{"x.s.Workers[].Busy (id busy): index attribute worker dropped"}, messages(diff(catalogs(busy), catalogs(single))))
+	})
+}
+
+func messages(changes []change) []string {
+	s := make([]string, len(changes))
+	for i, c := range changes {
+		s[i] = c.String()
+	}
+	return s
+}
+
+func TestReadCatalogs(t *testing.T) {
+	t.Run("Does read JSON and YAML catalogs", func(t *testing.T) {
+		dir := t.TempDir()
+		want := []em.Catalog{{Type: "x.s", Metrics: []em.Metric{{Path: "Requests", ID: "requests", Kind: "counter", Number: "int64", PrometheusName: "requests_total"}}}}
+		files := map[string]string{
+			"metrics.json": `[{"type":"x.s","metrics":[{"path":"Requests","id":"requests","kind":"counter","number":"int64","prometheusName":"requests_total"}]}]`,
+			"metrics.yaml": "- type: x.s\n  metrics:\n    - path: Requests\n      id: requests\n      kind: counter\n      number: int64\n      prometheusName: requests_total\n",
+		}
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			catalogs, err := readCatalogs(path)
+			require.NoError(t, err)
+			require.Equal(t, want, catalogs, name)
+		}
+	})
+
+	t.Run("Fails with missing files", func(t *testing.T) {
+		_, err := readCatalogs(filepath.Join(t.TempDir(), "metrics.json"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
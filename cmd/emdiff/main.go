@@ -0,0 +1,48 @@
+// Command emdiff reports the breaking changes between two versions of the
+// metric catalogs written by emdoc: removed metrics, renamed ids, kind, number
+// type, unit and bucket changes, and dropped or changed attributes. It exits
+// with status 1 if any is found, so that CI fails before dashboards and alerts
+// silently break.
+//
+// Catalogs are read from JSON or YAML files, or from git revisions as rev:path:
+//
+//	go run github.com/ofeefo/em/cmd/emdiff origin/main:./metrics.json metrics.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: emdiff old new\n\nold and new are JSON or YAML catalogs written by emdoc, as files or rev:path git objects.\n")
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	old, err := readCatalogs(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "emdiff: %s\n", err)
+		os.Exit(2)
+	}
+	updated, err := readCatalogs(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "emdiff: %s\n", err)
+		os.Exit(2)
+	}
+
+	changes := diff(old, updated)
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	if len(changes) > 0 {
+		fmt.Fprintf(os.Stderr, "emdiff: %d breaking changes\n", len(changes))
+		os.Exit(1)
+	}
+}
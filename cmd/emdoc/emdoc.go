@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ofeefo/em"
+)
+
+// describeTest is the test emdoc runs in the described package, writing the
+// catalogs of the requested types as JSON to the file named by $EMDOC_OUTPUT.
+// Imports are aliased not to conflict with package-level declarations.
+const describeTest = `// Code generated by emdoc. DO NOT EDIT.
+
+package %s
+
+import (
+	emdocEM "github.com/ofeefo/em"
+	emdocJSON "encoding/json"
+	emdocOS "os"
+	emdocTesting "testing"
+)
+
+func %s(t *emdocTesting.T) {
+	output := emdocOS.Getenv("EMDOC_OUTPUT")
+	if output == "" {
+		t.Skip("not run by emdoc")
+	}
+
+	var catalogs []emdocEM.Catalog
+	add := func(c emdocEM.Catalog, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		catalogs = append(catalogs, c)
+	}
+%s
+
+	b, err := emdocJSON.Marshal(catalogs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = emdocOS.WriteFile(output, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+`
+
+// describe returns the catalogs of the named types of the package in dir.
+func describe(dir string, typeNames []string) ([]em.Catalog, error) {
+	pkg, err := goCmd(dir, nil, "list", "-f", "{{.Name}}", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 8)
+	if _, err = rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(suffix)
+	testName := "TestEmdoc" + id
+
+	var describers strings.Builder
+	for _, name := range typeNames {
+		fmt.Fprintf(&describers, "\tadd(emdocEM.Describe[%s]())\n", strings.TrimSpace(name))
+	}
+
+	// The test is added to the package through an overlay, leaving its
+	// directory untouched.
+	tmp, err := os.MkdirTemp("", "emdoc-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	testFile := filepath.Join(tmp, "emdoc_test.go")
+	src := fmt.Sprintf(describeTest, strings.TrimSpace(pkg), testName, describers.String())
+	if err = os.WriteFile(testFile, []byte(src), 0o600); err != nil {
+		return nil, err
+	}
+	overlay, err := json.Marshal(map[string]map[string]string{
+		"Replace": {filepath.Join(abs, "emdoc_"+id+"_test.go"): testFile},
+	})
+	if err != nil {
+		return nil, err
+	}
+	overlayFile := filepath.Join(tmp, "overlay.json")
+	if err = os.WriteFile(overlayFile, overlay, 0o600); err != nil {
+		return nil, err
+	}
+
+	output := filepath.Join(tmp, "catalogs.json")
+	env := []string{"EMDOC_OUTPUT=" + output}
+	if _, err = goCmd(dir, env, "test", "-count=1", "-overlay", overlayFile, "-run", "^"+testName+"$", "."); err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(output)
+	if err != nil {
+		return nil, err
+	}
+	var catalogs []em.Catalog
+	if err = json.Unmarshal(b, &catalogs); err != nil {
+		return nil, fmt.Errorf("failed reading catalogs: %s", err)
+	}
+	return catalogs, nil
+}
+
+// goCmd runs the go command in dir, returning its standard output.
+func goCmd(dir string, env []string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		out := strings.TrimSpace(stderr.String() + stdout.String())
+		return "", fmt.Errorf("go %s failed: %s\n%s", args[0], err, out)
+	}
+	return stdout.String(), nil
+}
+
+// writers write catalogs in the supported formats.
+var writers = map[string]func(w io.Writer, catalogs []em.Catalog) error{
+	"json":     writeJSON,
+	"yaml":     writeYAML,
+	"markdown": writeMarkdown,
+}
+
+func writeJSON(w io.Writer, catalogs []em.Catalog) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(catalogs)
+}
+
+func writeYAML(w io.Writer, catalogs []em.Catalog) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(catalogs); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func writeMarkdown(w io.Writer, catalogs []em.Catalog) error {
+	var b strings.Builder
+	for i, c := range catalogs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "## %s\n\n", c.Type)
+		b.WriteString("| Metric | Prometheus | Kind | Type | Unit | Attributes | Aggregation | Description | Field |\n")
+		b.WriteString("|---|---|---|---|---|---|---|---|---|\n")
+		for _, m := range c.Metrics {
+			cells := []string{
+				code(m.ID),
+				code(m.PrometheusName),
+				m.Kind,
+				m.Type,
+				m.Unit,
+				attributes(m),
+				aggregation(m),
+				m.Description,
+				code(m.Path),
+			}
+			for i, cell := range cells {
+				cells[i] = strings.ReplaceAll(strings.ReplaceAll(cell, "|", `\|`), "\n", " ")
+			}
+			fmt.Fprintf(&b, "| %s |\n", strings.Join(cells, " | "))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func code(s string) string {
+	return "`" + s + "`"
+}
+
+// attributes lists the static attributes of m, followed by the key of
+// families and the indexes of elements.
+func attributes(m em.Metric) string {
+	keys := make([]string, 0, len(m.Attributes))
+	for k := range m.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]string, 0, len(keys)+len(m.Indexes)+1)
+	for _, k := range keys {
+		attrs = append(attrs, code(k+"="+m.Attributes[k]))
+	}
+	if m.Key != "" {
+		attrs = append(attrs, code(m.Key)+" (key)")
+	}
+	for _, index := range m.Indexes {
+		attrs = append(attrs, code(index)+" (index)")
+	}
+	return strings.Join(attrs, ", ")
+}
+
+// aggregation describes the buckets of histograms.
+func aggregation(m em.Metric) string {
+	switch {
+	case m.Exponential != nil:
+		return fmt.Sprintf("exponential (maxsize %d, maxscale %d)", m.Exponential.MaxSize, m.Exponential.MaxScale)
+	case len(m.Buckets) > 0:
+		bounds := make([]string, len(m.Buckets))
+		for i, b := range m.Buckets {
+			bounds[i] = strconv.FormatFloat(b, 'g', -1, 64)
+		}
+		return "buckets " + strings.Join(bounds, ", ")
+	case m.Kind == "histogram":
+		return "default buckets"
+	}
+	return ""
+}
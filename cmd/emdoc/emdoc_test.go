@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ofeefo/em"
+)
+
+func TestDescribe(t *testing.T) {
+	t.Run("Output matches the committed sample", func(t *testing.T) {
+		expected, err := os.ReadFile("internal/sample/METRICS.md")
+		require.NoError(t, err)
+
+		catalogs, err := describe("internal/sample", []string{"server", "queue"})
+		require.NoError(t, err)
+
+		var out bytes.Buffer
+		require.NoError(t, writeMarkdown(&out, catalogs))
+		require.Equal(t, string(expected), out.String())
+
+		files, err := filepath.Glob("internal/sample/emdoc_*_test.go")
+		require.NoError(t, err)
+		require.Empty(t, files)
+	})
+
+	t.Run("Does not write to the package directory", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("read-only directories are writable by root")
+		}
+		dir := t.TempDir()
+		src := "package x\n\nimport \"github.com/ofeefo/em\"\n\ntype s struct {\nCounter em.I64Counter `id:\"c\"`\n}\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "x.go"), []byte(src), 0o600))
+		writeModule(t, dir)
+		require.NoError(t, os.Chmod(dir, 0o500))
+		t.Cleanup(func() { require.NoError(t, os.Chmod(dir, 0o700)) })
+
+		catalogs, err := describe(dir, []string{"s"})
+		require.NoError(t, err)
+		require.Len(t, catalogs, 1)
+	})
+
+	t.Run("Fails with misconfigured fields", func(t *testing.T) {
+		dir := t.TempDir()
+		src := "package x\n\nimport \"github.com/ofeefo/em\"\n\ntype s struct {\nCounter em.I64Counter `id:\"1st\"`\n}\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "x.go"), []byte(src), 0o600))
+		writeModule(t, dir)
+
+		_, err := describe(dir, []string{"s"})
+		require.ErrorContains(t, err, "field Counter")
+	})
+
+	t.Run("Fails with unknown types", func(t *testing.T) {
+		_, err := describe("internal/sample", []string{"Unknown"})
+		require.ErrorContains(t, err, "undefined: Unknown")
+	})
+}
+
+// writeModule writes to dir a module requiring em from this one, so that
+// packages created outside of it can be described.
+func writeModule(t *testing.T, dir string) {
+	root, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	mod, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	require.NoError(t, err)
+	sum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	require.NoError(t, err)
+
+	mod = append([]byte("module x\n\ngo 1.23.0\n\nrequire github.com/ofeefo/em v0.0.0\n\nreplace github.com/ofeefo/em => "+root+"\n\n"),
+		mod[bytes.Index(mod, []byte("require")):]...)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), mod, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.sum"), sum, 0o600))
+}
+
+func TestWriters(t *testing.T) {
+	catalogs := []em.Catalog{{
+		Type: "x.s",
+		Metrics: []em.Metric{{
+			Path:           "Latency",
+			ID:             "latency",
+			Type:           "F64Histogram",
+			Kind:           "histogram",
+			Number:         "float64",
+			Unit:           "ms",
+			Buckets:        []float64{1, 2.5},
+			Attributes:     map[string]string{"b": "2", "a": "1"},
+			PrometheusName: "latency_milliseconds",
+		}},
+	}}
+
+	t.Run("Does write JSON", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, writeJSON(&out, catalogs))
+		var decoded []em.Catalog
+		require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+		require.Equal(t, catalogs, decoded)
+	})
+
+	t.Run("Does write YAML", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, writeYAML(&out, catalogs))
+		var decoded []em.Catalog
+		require.NoError(t, yaml.Unmarshal(out.Bytes(), &decoded))
+		require.Equal(t, catalogs, decoded)
+	})
+
+	t.Run("Does write sorted attributes in Markdown", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, writeMarkdown(&out, catalogs))
+		require.Contains(t, out.String(), "| `latency` | `latency_milliseconds` | histogram | F64Histogram | ms | `a=1`, `b=2` | buckets 1, 2.5 |  | `Latency` |\n")
+	})
+
+	t.Run("Does pick the format from the output extension", func(t *testing.T) {
+		require.Equal(t, "json", formatFor("metrics.json"))
+		require.Equal(t, "yaml", formatFor("metrics.yml"))
+		require.Equal(t, "markdown", formatFor("METRICS.md"))
+		require.Equal(t, "markdown", formatFor(""))
+	})
+}
@@ -0,0 +1,34 @@
+// Package sample declares instrument structs used to verify the catalogs
+// written by emdoc.
+package sample
+
+import (
+	"context"
+
+	"github.com/ofeefo/em"
+)
+
+//go:generate go run github.com/ofeefo/em/cmd/emdoc -type server,queue -output METRICS.md
+
+type server struct {
+	Requests em.I64Counter                    `id:"http.server.requests" desc:"Requests served"`
+	Latency  em.F64Histogram                  `id:"http.server.latency" unit:"ms" buckets:"5,10,50" desc:"Request latency | handler only"`
+	Duration em.Timer                         `id:"http.server.duration"`
+	PerRoute em.Family[string, em.I64Counter] `id:"http.server.routes" key:"route" maxkeys:"10"`
+	Sizes    em.F64Histogram                  `id:"http.server.sizes" unit:"By" aggregation:"exponential" maxsize:"160" maxscale:"20"`
+	Upstream struct {
+		Errors em.I64Counter `id:"errors"`
+	} `prefix:"upstream." attrs:"service,billing"`
+}
+
+type queue struct {
+	Workers [2]struct {
+		Busy em.I64Gauge `id:"busy"`
+	} `prefix:"queue.worker." index:"worker"`
+	Depth em.I64ObservableGauge `id:"queue.depth" callback:"ObserveDepth"`
+}
+
+func (q *queue) ObserveDepth(_ context.Context, o em.I64Observer) error {
+	o.Observe(0)
+	return nil
+}
@@ -0,0 +1,86 @@
+// Command emdoc writes the catalog of the metrics declared by em instrument
+// structs, as returned by em.Describe, in JSON, YAML or as a Markdown table.
+//
+// The structs are described by the package declaring them: emdoc runs a
+// temporary test calling em.Describe in it, so that unexported types are
+// supported too. The test is added through a build overlay, without writing
+// to the package directory. It is meant to be used through go:generate:
+//
+//	//go:generate go run github.com/ofeefo/em/cmd/emdoc -type instruments -output METRICS.md
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	var (
+		types  = flag.String("type", "", "comma-separated list of instrument struct names; required")
+		format = flag.String("format", "", "output format, one of json, yaml or markdown; defaults to the output extension, or markdown")
+		output = flag.String("output", "", "output file name, relative to the package directory; defaults to the standard output")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: emdoc -type T [-format json|yaml|markdown] [-output file] [directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *types == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	if *format == "" {
+		*format = formatFor(*output)
+	}
+	write, ok := writers[*format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "emdoc: unsupported format %q, expected json, yaml or markdown\n", *format)
+		os.Exit(2)
+	}
+
+	catalogs, err := describe(dir, strings.Split(*types, ","))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "emdoc: %s\n", err)
+		os.Exit(1)
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		name := *output
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		// nolint: gosec
+		if out, err = os.Create(name); err != nil {
+			fmt.Fprintf(os.Stderr, "emdoc: %s\n", err)
+			os.Exit(1)
+		}
+		defer out.Close()
+	}
+
+	if err = write(out, catalogs); err != nil {
+		fmt.Fprintf(os.Stderr, "emdoc: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// formatFor returns the format matching the extension of the output file.
+func formatFor(output string) string {
+	switch filepath.Ext(output) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return "markdown"
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/ofeefo/em/internal/instrument"
+)
+
+// external checks a field declared with the type sel of another package,
+// possibly through a pointer or as the elements of an array or slice. em.Init
+// initializes the instruments of such structs, which emgen can't generate as
+// it only parses the package at hand, so they are reported rather than left
+// nil.
+func (g *generator) external(sel *ast.SelectorExpr, path string) error {
+	x, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	pkgPath, ok := g.imports[g.fset.Position(sel.Pos()).Filename][x.Name]
+	if !ok {
+		return nil
+	}
+
+	pkg, err := g.load(pkgPath)
+	if err != nil {
+		return fmt.Errorf("field %s: %s", path, err)
+	}
+	obj := pkg.Scope().Lookup(sel.Sel.Name)
+	if obj == nil || !holdsInstruments(obj.Type(), map[types.Type]bool{}) {
+		return nil
+	}
+	return fmt.Errorf("field %s: %s.%s of another package holds instruments, which emgen can't initialize", path, x.Name, sel.Sel.Name)
+}
+
+// load returns the types of the package imported as path, read from the
+// export data built by the go command.
+func (g *generator) load(path string) (*types.Package, error) {
+	if pkg, ok := g.loaded[path]; ok {
+		return pkg, nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("go", "list", "-export", "-deps", "-f", "{{.ImportPath}} {{.Export}}", path)
+	cmd.Dir = g.dir
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed loading package %s: %s", path, strings.TrimSpace(stderr.String()))
+	}
+
+	exports := map[string]string{}
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		if pkgPath, export, ok := strings.Cut(scanner.Text(), " "); ok {
+			exports[pkgPath] = export
+		}
+	}
+
+	imp := importer.ForCompiler(g.fset, "gc", func(path string) (io.ReadCloser, error) {
+		export, ok := exports[path]
+		if !ok || export == "" {
+			return nil, fmt.Errorf("no export data for package %s", path)
+		}
+		// nolint: gosec
+		return os.Open(export)
+	})
+	pkg, err := imp.Import(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed loading package %s: %s", path, err)
+	}
+	g.loaded[path] = pkg
+	return pkg, nil
+}
+
+// holdsInstruments tells whether em.Init initializes any instrument within a
+// field of type t.
+func holdsInstruments(t types.Type, seen map[types.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	if named, ok := t.(*types.Named); ok && isEMType(named) {
+		return true
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		return holdsInstruments(u.Elem(), seen)
+	case *types.Array:
+		return holdsInstruments(u.Elem(), seen)
+	case *types.Slice:
+		return holdsInstruments(u.Elem(), seen)
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if f := u.Field(i); f.Exported() && holdsInstruments(f.Type(), seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isEMType tells whether named is an em instrument or family type, or a type
+// defined on top of an em instrument one.
+func isEMType(named *types.Named) bool {
+	obj := named.Obj()
+	if obj.Pkg() == nil {
+		return false
+	}
+	if obj.Pkg().Path() == instrument.Path {
+		_, ok := instrument.Types[obj.Name()]
+		return ok || obj.Name() == "Family"
+	}
+
+	if _, ok := named.Underlying().(*types.Interface); !ok {
+		return false
+	}
+	for _, imp := range obj.Pkg().Imports() {
+		if imp.Path() != instrument.Path {
+			continue
+		}
+		for name := range instrument.Types {
+			if emObj := imp.Scope().Lookup(name); emObj != nil && types.Identical(named.Underlying(), emObj.Type().Underlying()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fileImports returns the import paths of f by the name they are imported as.
+func fileImports(f *ast.File) map[string]string {
+	imports := map[string]string{}
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		name := importName(path)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		imports[name] = path
+	}
+	return imports
+}
+
+// importName returns the name packages are conventionally imported as: the
+// last element of their path, skipping major version suffixes.
+func importName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	return name
+}
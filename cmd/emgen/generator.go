@@ -0,0 +1,640 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/ofeefo/em"
+	"github.com/ofeefo/em/internal/instrument"
+)
+
+type typeDecl struct {
+	spec *ast.TypeSpec
+	// emName is the name under which em is imported by the declaring file.
+	emName string
+}
+
+type generator struct {
+	dir   string
+	pkg   string
+	fset  *token.FileSet
+	types map[string]typeDecl
+	// imports maps the files of the package to their import paths by name,
+	// and loaded caches the types of the packages loaded to check fields
+	// declared with their types.
+	imports map[string]map[string]string
+	loaded  map[string]*types.Package
+	buf     bytes.Buffer
+	// body holds the statements initializing the fields of the current
+	// constructor, and attrVars counts the attribute slices declared in it.
+	body     bytes.Buffer
+	attrVars int
+	// familyVars counts the family base instruments declared in it, and
+	// loopVars the indexes of the loops initializing elements.
+	familyVars int
+	loopVars   int
+	// prefix is prepended to the ids of the instruments of the struct being
+	// initialized, composed from the 'prefix' tags of its enclosing fields.
+	prefix string
+	// initializes tells whether any constructor initializes instruments,
+	// requiring fmt to be imported.
+	initializes bool
+}
+
+func generate(dir, output string, typeNames []string) ([]byte, error) {
+	g := &generator{
+		dir:     dir,
+		types:   map[string]typeDecl{},
+		imports: map[string]map[string]string{},
+		loaded:  map[string]*types.Package{},
+	}
+	if err := g.parseDir(dir, output); err != nil {
+		return nil, err
+	}
+
+	for _, name := range typeNames {
+		if err := g.constructor(strings.TrimSpace(name)); err != nil {
+			return nil, err
+		}
+	}
+
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, "// Code generated by emgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(out, "package %s\n\nimport (\n", g.pkg)
+	if g.initializes {
+		fmt.Fprintf(out, "\"fmt\"\n\n")
+	}
+	fmt.Fprintf(out, "\"go.opentelemetry.io/otel/attribute\"\n\n%q\n", instrument.Path)
+	fmt.Fprintf(out, ")\n")
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed formatting generated code: %s", err)
+	}
+	return src, nil
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) bodyf(format string, args ...any) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+func (g *generator) parseDir(dir, output string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	g.fset = token.NewFileSet()
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || name == output {
+			continue
+		}
+
+		fName := filepath.Join(dir, name)
+		f, err := parser.ParseFile(g.fset, fName, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+		g.pkg = f.Name.Name
+		g.imports[fName] = fileImports(f)
+		g.collectTypes(f)
+	}
+	return nil
+}
+
+func (g *generator) collectTypes(f *ast.File) {
+	emName := ""
+	for _, imp := range f.Imports {
+		if path, _ := strconv.Unquote(imp.Path.Value); path == instrument.Path {
+			emName = "em"
+			if imp.Name != nil {
+				emName = imp.Name.Name
+			}
+		}
+	}
+
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, s := range gd.Specs {
+			ts := s.(*ast.TypeSpec)
+			g.types[ts.Name.Name] = typeDecl{spec: ts, emName: emName}
+		}
+	}
+}
+
+func (g *generator) constructor(name string) error {
+	decl, ok := g.types[name]
+	if !ok {
+		return fmt.Errorf("type %s not found", name)
+	}
+
+	st, ok := decl.spec.Type.(*ast.StructType)
+	if !ok {
+		return fmt.Errorf("type %s is not a struct", name)
+	}
+
+	fn := "New" + upperFirst(name)
+	if !ast.IsExported(name) {
+		fn = "new" + upperFirst(name)
+	}
+
+	g.attrVars, g.familyVars, g.loopVars = 0, 0, 0
+	g.body.Reset()
+	if err := g.fields(st, decl.emName, "s", "", "attrs"); err != nil {
+		return fmt.Errorf("type %s: %s", name, err)
+	}
+
+	g.printf("\n// %s initializes the instruments of %s through r, as em.InitIn would.\n", fn, name)
+	g.printf("func %s(r *em.Registry, attrs ...attribute.KeyValue) (*%s, error) {\n", fn, name)
+	g.printf("s := &%s{}\n", name)
+	if g.body.Len() > 0 {
+		g.printf("ns := r.Namespace()\n")
+		g.printf("var err error\n\n")
+		g.buf.Write(g.body.Bytes())
+	}
+	g.printf("return s, nil\n}\n")
+	return nil
+}
+
+// fields emits the initialization of every field of st, where recv is the
+// expression selecting the struct, path is its dotted field path and
+// attrsVar the variable holding its attributes.
+func (g *generator) fields(st *ast.StructType, emName, recv, path, attrsVar string) error {
+	for _, field := range st.Fields.List {
+		names := fieldNames(field)
+		for _, fName := range names {
+			if !ast.IsExported(fName) {
+				continue
+			}
+
+			tag := reflect.StructTag("")
+			if field.Tag != nil {
+				raw, _ := strconv.Unquote(field.Tag.Value)
+				tag = reflect.StructTag(raw)
+			}
+
+			fPath := fName
+			if path != "" {
+				fPath = path + "." + fName
+			}
+
+			if err := g.field(field.Type, tag, emName, recv+"."+fName, fPath, attrsVar); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// field emits the initialization of a single field. As with em.Init, only
+// em instruments and (pointers to) structs declared in the package are
+// initialized, and fields of any other type are left untouched.
+func (g *generator) field(expr ast.Expr, tag reflect.StructTag, emName, sel, path, attrsVar string) error {
+	if name, identical, ok := g.emType(expr, emName); ok {
+		typeName, err := resolveType(name, identical, tag, path)
+		if err != nil {
+			return err
+		}
+		return g.instrument(typeName, tag, sel, path, attrsVar)
+	}
+
+	switch t := expr.(type) {
+	case *ast.IndexListExpr:
+		if x, ok := t.X.(*ast.SelectorExpr); ok && len(t.Indices) == 2 {
+			if pkg, ok := x.X.(*ast.Ident); ok && pkg.Name == emName && x.Sel.Name == "Family" {
+				return g.family(t, tag, emName, sel, path, attrsVar)
+			}
+		}
+	case *ast.ArrayType:
+		if g.isStruct(t.Elt) {
+			return g.elements(t, tag, emName, sel, path, attrsVar)
+		}
+		if x, ok := selector(t.Elt); ok {
+			return g.external(x, path)
+		}
+	case *ast.SelectorExpr:
+		return g.external(t, path)
+	case *ast.StructType:
+		return g.nested(t, tag, emName, sel, path, attrsVar)
+	case *ast.Ident:
+		if decl, st := g.localStruct(t.Name); st != nil {
+			return g.nested(st, tag, decl.emName, sel, path, attrsVar)
+		}
+	case *ast.StarExpr:
+		if x, ok := t.X.(*ast.SelectorExpr); ok {
+			return g.external(x, path)
+		}
+		ident, ok := t.X.(*ast.Ident)
+		if !ok {
+			return fmt.Errorf("field %s: unsupported pointer type", path)
+		}
+		if decl, st := g.localStruct(ident.Name); st != nil {
+			g.bodyf("%s = &%s{}\n", sel, ident.Name)
+			return g.nested(st, tag, decl.emName, sel, path, attrsVar)
+		}
+	}
+	return nil
+}
+
+// emType returns the name of the em instrument type expr is declared with,
+// either directly or through types declared in the package, and whether expr
+// is identical to it (rather than a type defined on top of it).
+func (g *generator) emType(expr ast.Expr, emName string) (name string, identical, ok bool) {
+	identical = true
+	for depth := 0; depth < len(g.types)+1; depth++ {
+		switch t := expr.(type) {
+		case *ast.SelectorExpr:
+			x, isIdent := t.X.(*ast.Ident)
+			if !isIdent || x.Name != emName {
+				return "", false, false
+			}
+			_, ok = instrument.Types[t.Sel.Name]
+			return t.Sel.Name, identical, ok
+		case *ast.Ident:
+			decl, found := g.types[t.Name]
+			if !found {
+				return "", false, false
+			}
+			identical = identical && decl.spec.Assign.IsValid()
+			expr, emName = decl.spec.Type, decl.emName
+		default:
+			return "", false, false
+		}
+	}
+	return "", false, false
+}
+
+// resolveType returns the em type initializing a field declared with the
+// named em type. As em.Init only sees the method set of types defined on top
+// of em ones, the 'kind' tag tells them apart, following the same rules.
+func resolveType(name string, identical bool, tag reflect.StructTag, path string) (string, error) {
+	candidates := []string{name}
+	if !identical {
+		candidates = instrument.Group(name)
+	}
+
+	res, err := instrument.Resolve(candidates, tag.Get("kind"))
+	if err != nil {
+		return "", fmt.Errorf("field %s: %s", path, err)
+	}
+
+	// em.Init picks the number type of observables from their callback,
+	// which must match the declared type for the generated code to compile.
+	if len(res) > 1 && instrument.Types[name].Observable {
+		var filtered []string
+		for _, r := range res {
+			if r[:3] == name[:3] {
+				filtered = append(filtered, r)
+			}
+		}
+		res = filtered
+	}
+
+	if len(res) > 1 {
+		return "", fmt.Errorf("field %s: ambiguous kind for a type defined on %s, which could be any of %s: set the kind tag", path, name, strings.Join(res, ", "))
+	}
+	return res[0], nil
+}
+
+// selector returns the type of another package expr is declared with, or
+// points to.
+func selector(expr ast.Expr) (*ast.SelectorExpr, bool) {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	x, ok := expr.(*ast.SelectorExpr)
+	return x, ok
+}
+
+// isStruct tells whether expr is a struct type, or a pointer to one declared
+// in the package.
+func (g *generator) isStruct(expr ast.Expr) bool {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch t := expr.(type) {
+	case *ast.StructType:
+		return true
+	case *ast.Ident:
+		_, st := g.localStruct(t.Name)
+		return st != nil
+	}
+	return false
+}
+
+// elements emits the initialization of every element of an array or slice
+// field, as nested structs with an attribute holding their index. As with
+// em.Init, slices without a 'len' tag are left untouched.
+func (g *generator) elements(t *ast.ArrayType, tag reflect.StructTag, emName, sel, path, attrsVar string) error {
+	defer g.enter(tag)()
+
+	if t.Len == nil {
+		raw := tag.Get("len")
+		if raw == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || n < 0 {
+			return fmt.Errorf("field %s: invalid len %q", path, raw)
+		}
+
+		var typ bytes.Buffer
+		if err = printer.Fprint(&typ, g.fset, t); err != nil {
+			return fmt.Errorf("field %s: %s", path, err)
+		}
+		g.bodyf("%s = make(%s, %d)\n", sel, typ.String(), n)
+	}
+
+	attrs, err := em.ParseAttrs(tag.Get("attrs"))
+	if err != nil {
+		return fmt.Errorf("field %s: %s", path, err)
+	}
+	key := tag.Get("index")
+	if key == "" {
+		key = "index"
+	}
+
+	g.loopVars++
+	idx := fmt.Sprintf("i%d", g.loopVars)
+	g.attrVars++
+	inner := fmt.Sprintf("attrs%d", g.attrVars)
+	exprs := fmt.Sprintf("attribute.Int(%q, %s)", key, idx)
+	if len(attrs) > 0 {
+		exprs = attrExprs(attrs) + ", " + exprs
+	}
+
+	start := g.body.Len()
+	g.bodyf("for %s := range %s {\n", idx, sel)
+	g.bodyf("%s := append(%s[:len(%s):len(%s)], %s)\n", inner, attrsVar, attrsVar, attrsVar, exprs)
+	header := g.body.Len()
+	if err = g.field(t.Elt, "", emName, sel+"["+idx+"]", path+"[]", inner); err != nil {
+		return err
+	}
+	if g.body.Len() == header {
+		// Elements without instruments need no loop.
+		g.body.Truncate(start)
+		return nil
+	}
+	g.bodyf("}\n")
+	return nil
+}
+
+func (g *generator) localStruct(name string) (typeDecl, *ast.StructType) {
+	decl, ok := g.types[name]
+	if !ok {
+		return decl, nil
+	}
+	st, _ := decl.spec.Type.(*ast.StructType)
+	return decl, st
+}
+
+func (g *generator) nested(st *ast.StructType, tag reflect.StructTag, emName, sel, path, attrsVar string) error {
+	defer g.enter(tag)()
+
+	attrs, err := em.ParseAttrs(tag.Get("attrs"))
+	if err != nil {
+		return fmt.Errorf("field %s: %s", path, err)
+	}
+
+	if len(attrs) > 0 {
+		g.attrVars++
+		inner := fmt.Sprintf("attrs%d", g.attrVars)
+		g.bodyf("%s := append(%s[:len(%s):len(%s)], %s)\n", inner, attrsVar, attrsVar, attrsVar, attrExprs(attrs))
+		attrsVar = inner
+	}
+	return g.fields(st, emName, sel, path, attrsVar)
+}
+
+// enter appends the 'prefix' tag of a nested struct field to the current
+// prefix, returning a function restoring it.
+func (g *generator) enter(tag reflect.StructTag) func() {
+	prefix := g.prefix
+	g.prefix += tag.Get("prefix")
+	return func() {
+		g.prefix = prefix
+	}
+}
+
+func (g *generator) instrument(typeName string, tag reflect.StructTag, sel, path, attrsVar string) error {
+	args, err := constructorArgs(typeName, tag, g.prefix, sel, path)
+	if err != nil {
+		return err
+	}
+
+	g.initializes = true
+	g.bodyf("if %s, err = em.New%s(%s, %s...); err != nil {\n", sel, typeName, args, attrsVar)
+	g.bodyf("return nil, fmt.Errorf(\"error initializing field %s: %%w\", err)\n}\n", path)
+	return nil
+}
+
+// family emits the initialization of an em.Family field, whose instrument is
+// created from the tags of the field as for any other instrument.
+func (g *generator) family(t *ast.IndexListExpr, tag reflect.StructTag, emName, sel, path, attrsVar string) error {
+	key := tag.Get("key")
+	if key == "" {
+		return fmt.Errorf("missing key tag for field %s", path)
+	}
+	maxKeys := 0
+	if raw := tag.Get("maxkeys"); raw != "" {
+		var err error
+		if maxKeys, err = strconv.Atoi(strings.TrimSpace(raw)); err != nil || maxKeys < 0 {
+			return fmt.Errorf("field %s: invalid maxkeys %q", path, raw)
+		}
+	}
+
+	name, identical, ok := g.emType(t.Indices[1], emName)
+	if !ok {
+		return fmt.Errorf("field %s: unsupported family instrument type", path)
+	}
+	typeName, err := resolveType(name, identical, tag, path)
+	if err != nil {
+		return err
+	}
+	if instrument.Types[typeName].Observable {
+		return fmt.Errorf("field %s: observable instruments are not supported by families", path)
+	}
+
+	kExpr, err := typeExpr(t.Indices[0], emName)
+	if err != nil {
+		return fmt.Errorf("field %s: %s", path, err)
+	}
+	iExpr, err := typeExpr(t.Indices[1], emName)
+	if err != nil {
+		return fmt.Errorf("field %s: %s", path, err)
+	}
+	args, err := constructorArgs(typeName, tag, g.prefix, sel, path)
+	if err != nil {
+		return err
+	}
+
+	g.initializes = true
+	g.familyVars++
+	base := fmt.Sprintf("base%d", g.familyVars)
+	g.bodyf("%s, err := em.New%s(%s, %s...)\n", base, typeName, args, attrsVar)
+	g.bodyf("if err != nil {\nreturn nil, fmt.Errorf(\"error initializing field %s: %%w\", err)\n}\n", path)
+	g.bodyf("if %s, err = em.NewFamily[%s, %s](%s, %q, %d); err != nil {\n", sel, kExpr, iExpr, base, key, maxKeys)
+	g.bodyf("return nil, fmt.Errorf(\"error initializing field %s: %%w\", err)\n}\n", path)
+	return nil
+}
+
+// typeExpr returns the source of a type argument of a family, as written from
+// the generated file.
+func typeExpr(expr ast.Expr, emName string) (string, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name, nil
+	case *ast.SelectorExpr:
+		if x, ok := t.X.(*ast.Ident); ok && x.Name == emName {
+			return "em." + t.Sel.Name, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported family type argument")
+}
+
+// constructorArgs returns the arguments of the em constructor of typeName
+// preceding attributes, prepending prefix to its id.
+func constructorArgs(typeName string, tag reflect.StructTag, prefix, sel, path string) (string, error) {
+	id := tag.Get("id")
+	if id == "" {
+		return "", fmt.Errorf("missing id tag for field %s", path)
+	}
+
+	if err := em.ValidateID(prefix + id); err != nil {
+		return "", fmt.Errorf("field %s: %s", path, err)
+	}
+
+	spec := fmt.Sprintf("ID: ns + %q", prefix+id)
+	if desc := tag.Get("desc"); desc != "" {
+		spec += fmt.Sprintf(", Description: %q", desc)
+	}
+	if unit := tag.Get("unit"); unit != "" {
+		spec += fmt.Sprintf(", Unit: %q", unit)
+	}
+	info := instrument.Types[typeName]
+	if unit := tag.Get("unit"); info.Timer && unit != "" && unit != "s" && unit != "ms" {
+		return "", fmt.Errorf("field %s: unsupported unit %q for timer, expected s or ms", path, unit)
+	}
+	if info.Histogram {
+		bounds, err := em.ParseBuckets(tag.Get("buckets"))
+		if err != nil {
+			return "", fmt.Errorf("field %s: %s", path, err)
+		}
+		if len(bounds) > 0 {
+			spec += ", Buckets: " + floatsExpr(bounds)
+		}
+		exp, err := em.ParseAggregation(tag.Get("aggregation"), tag.Get("maxsize"), tag.Get("maxscale"))
+		if err != nil {
+			return "", fmt.Errorf("field %s: %s", path, err)
+		}
+		if exp != nil {
+			if len(bounds) > 0 {
+				return "", fmt.Errorf("field %s: buckets cannot be used with the exponential aggregation", path)
+			}
+			spec += fmt.Sprintf(", Exponential: &em.Exponential{MaxSize: %d, MaxScale: %d}", exp.MaxSize, exp.MaxScale)
+		}
+	}
+
+	args := fmt.Sprintf("r, em.Spec{%s}", spec)
+	if info.Observable {
+		cb := tag.Get("callback")
+		if cb == "" {
+			return "", fmt.Errorf("missing callback tag for field %s", path)
+		}
+		args += ", " + sel[:strings.LastIndex(sel, ".")] + "." + cb
+	}
+	return args, nil
+}
+
+func fieldNames(field *ast.Field) []string {
+	if len(field.Names) > 0 {
+		names := make([]string, 0, len(field.Names))
+		for _, n := range field.Names {
+			names = append(names, n.Name)
+		}
+		return names
+	}
+
+	// Embedded fields are named after their type.
+	expr := field.Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return []string{t.Name}
+	case *ast.SelectorExpr:
+		return []string{t.Sel.Name}
+	}
+	return nil
+}
+
+func attrExprs(attrs []attribute.KeyValue) string {
+	exprs := make([]string, 0, len(attrs))
+	for _, a := range attrs {
+		exprs = append(exprs, attrExpr(a))
+	}
+	return strings.Join(exprs, ", ")
+}
+
+func attrExpr(a attribute.KeyValue) string {
+	k := string(a.Key)
+	v := a.Value
+	switch v.Type() {
+	case attribute.BOOL:
+		return fmt.Sprintf("attribute.Bool(%q, %t)", k, v.AsBool())
+	case attribute.INT64:
+		return fmt.Sprintf("attribute.Int64(%q, %d)", k, v.AsInt64())
+	case attribute.FLOAT64:
+		return fmt.Sprintf("attribute.Float64(%q, %s)", k, floatExpr(v.AsFloat64()))
+	case attribute.BOOLSLICE:
+		return fmt.Sprintf("attribute.BoolSlice(%q, %#v)", k, v.AsBoolSlice())
+	case attribute.INT64SLICE:
+		return fmt.Sprintf("attribute.Int64Slice(%q, %#v)", k, v.AsInt64Slice())
+	case attribute.FLOAT64SLICE:
+		return fmt.Sprintf("attribute.Float64Slice(%q, %s)", k, floatsExpr(v.AsFloat64Slice()))
+	case attribute.STRINGSLICE:
+		return fmt.Sprintf("attribute.StringSlice(%q, %#v)", k, v.AsStringSlice())
+	}
+	return fmt.Sprintf("attribute.String(%q, %q)", k, v.AsString())
+}
+
+func floatsExpr(fs []float64) string {
+	exprs := make([]string, 0, len(fs))
+	for _, f := range fs {
+		exprs = append(exprs, floatExpr(f))
+	}
+	return "[]float64{" + strings.Join(exprs, ", ") + "}"
+}
+
+func floatExpr(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func upperFirst(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
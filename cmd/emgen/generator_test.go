@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Run("Output matches the committed sample", func(t *testing.T) {
+		expected, err := os.ReadFile("internal/sample/samplers_emgen.go")
+		require.NoError(t, err)
+
+		src, err := generate("internal/sample", "samplers_emgen.go", []string{"Samplers", "observed"})
+		require.NoError(t, err)
+		require.Equal(t, string(expected), string(src))
+	})
+
+	invalid := map[string]string{
+		"missing id": "Counter em.I64Counter",
+		"buckets":    "Histogram em.I64Histogram `id:\"h\" buckets:\"1,a\"`",
+		"attributes": "Nested struct{ Counter em.I64Counter `id:\"c\"` } `attrs:\"a,b,c\"`",
+		"callback":   "Gauge em.I64ObservableGauge `id:\"g\"`",
+		"kind":       "Counter em.I64Counter `id:\"c\" kind:\"gauge\"`",
+		"family key": "Family em.Family[string, em.I64Counter] `id:\"f\"`",
+		"family":     "Family em.Family[string, em.I64ObservableGauge] `id:\"f\" key:\"k\" callback:\"Observe\"`",
+		"len":        "Workers []struct{ Counter em.I64Counter `id:\"c\"` } `len:\"a\"`",
+		"id":         "Counter em.I64Counter `id:\"1st\"`",
+	}
+	for name, field := range invalid {
+		t.Run("Fails with invalid "+name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := "package x\n\nimport \"github.com/ofeefo/em\"\n\ntype s struct {\n" + field + "\n}\n"
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "x.go"), []byte(src), 0o600))
+
+			_, err := generate(dir, "s_emgen.go", []string{"s"})
+			require.Error(t, err)
+		})
+	}
+
+	t.Run("Fails with ambiguous kinds", func(t *testing.T) {
+		dir := t.TempDir()
+		src := "package x\n\nimport \"github.com/ofeefo/em\"\n\ntype requests em.I64Counter\n\ntype s struct {\nRequests requests `id:\"r\"`\n}\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "x.go"), []byte(src), 0o600))
+
+		_, err := generate(dir, "s_emgen.go", []string{"s"})
+		require.ErrorContains(t, err, "ambiguous kind")
+	})
+
+	t.Run("Fails with structs of other packages holding instruments", func(t *testing.T) {
+		dir := t.TempDir()
+		writeModule(t, dir)
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "ext"), 0o700))
+		ext := "package ext\n\nimport \"github.com/ofeefo/em\"\n\ntype Server struct {\nRequests em.I64Counter `id:\"r\"`\n}\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "ext", "ext.go"), []byte(ext), 0o600))
+
+		for _, field := range []string{"Ext ext.Server", "Ext *ext.Server", "Ext []ext.Server `len:\"2\"`"} {
+			src := "package x\n\nimport (\n\"time\"\n\n\"x/ext\"\n)\n\ntype s struct {\nStarted time.Time\n" + field + "\n}\n"
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "x.go"), []byte(src), 0o600))
+
+			_, err := generate(dir, "s_emgen.go", []string{"s"})
+			require.ErrorContains(t, err, "field Ext: ext.Server of another package holds instruments", field)
+		}
+	})
+
+	t.Run("Fails with unknown types", func(t *testing.T) {
+		_, err := generate("internal/sample", "out.go", []string{"Unknown"})
+		require.ErrorContains(t, err, "type Unknown not found")
+	})
+}
+
+// writeModule writes to dir a module named x requiring em from this one, so
+// that packages created outside of it can be type-checked.
+func writeModule(t *testing.T, dir string) {
+	root, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	mod, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	require.NoError(t, err)
+	sum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	require.NoError(t, err)
+
+	mod = append([]byte("module x\n\ngo 1.23.0\n\nrequire github.com/ofeefo/em v0.0.0\n\nreplace github.com/ofeefo/em => "+root+"\n\n"),
+		mod[bytes.Index(mod, []byte("require")):]...)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), mod, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.sum"), sum, 0o600))
+}
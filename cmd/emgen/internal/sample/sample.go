@@ -0,0 +1,77 @@
+// Package sample declares instrument structs used to verify that code
+// generated by emgen matches em.Init.
+package sample
+
+import (
+	"context"
+	"time"
+
+	"github.com/ofeefo/em"
+)
+
+//go:generate go run github.com/ofeefo/em/cmd/emgen -type Samplers,observed
+
+type Samplers struct {
+	Counter       em.I64Counter                    `id:"i_am_a_counter"`
+	Gauge         em.I64Gauge                      `id:"i_am_a_gauge"`
+	UpDownCounter em.F64UpDownCounter              `id:"i_am_a_updowncounter"`
+	Histogram     em.F64Histogram                  `id:"i_am_a_histogram" buckets:"1.0,2.0,3.0" desc:"A histogram" unit:"ms"`
+	Timer         em.Timer                         `id:"i_am_a_timer"`
+	Requests      requestCounter                   `id:"i_am_a_request_counter" kind:"counter"`
+	PerRoute      em.Family[string, em.I64Counter] `id:"i_am_a_family" key:"route" maxkeys:"1"`
+	Workers       [2]worker                        `index:"worker" attrs:"pool,main"`
+	Shards        []*worker                        `len:"2" prefix:"shard_"`
+	Plain         []struct{ Name string }          `len:"2"`
+	Nested        nested                           `attrs:"sub,nested,gotta,bar"`
+	Replica       *nested                          `prefix:"replica_"`
+	*Embedded     `attrs:"sub,embedded,gotta,bar2"`
+
+	Name    string
+	Started time.Time
+}
+
+// requestCounter is a domain-named instrument type, told apart from an
+// up-down counter through the kind tag.
+type requestCounter em.I64Counter
+
+type nested struct {
+	Counter  em.F64Counter `id:"example_nested_counter"`
+	Gauge    em.F64Gauge   `id:"example_nested_gauge"`
+	MoreNest struct {
+		Counter em.F64Counter `id:"example_more_nested_counter"`
+	} `attrs:"more=nest,depth=int:2,ratio=float:0.5,flags=[]bool:true|false"`
+}
+
+type worker struct {
+	Processed em.I64Counter `id:"example_worker_processed"`
+}
+
+type Embedded struct {
+	Histogram     em.I64Histogram     `id:"example_embedded_histogram" aggregation:"exponential" maxscale:"10"`
+	UpDownCounter em.F64UpDownCounter `id:"example_embedded_updowncounter"`
+}
+
+type observed struct {
+	Depth em.I64ObservableGauge   `id:"queue_depth" callback:"ObserveDepth"`
+	Inner *inner                  `attrs:"inner,true"`
+	Ratio em.F64ObservableCounter `id:"ratio" callback:"ObserveRatio"`
+}
+
+type inner struct {
+	Size em.I64ObservableUpDownCounter `id:"pool_size" callback:"ObserveSize"`
+}
+
+func (o *observed) ObserveDepth(_ context.Context, obs em.I64Observer) error {
+	obs.Observe(3)
+	return nil
+}
+
+func (o *observed) ObserveRatio(_ context.Context, obs em.F64Observer) error {
+	obs.Observe(0.5)
+	return nil
+}
+
+func (i *inner) ObserveSize(_ context.Context, obs em.I64Observer) error {
+	obs.Observe(7)
+	return nil
+}
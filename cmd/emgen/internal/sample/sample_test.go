@@ -0,0 +1,73 @@
+package sample
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	m2 "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata/metricdatatest"
+
+	"github.com/ofeefo/em"
+)
+
+// TestGeneratedMatchesInit ensures generated constructors produce the same
+// instruments, buckets, aggregations and attributes as the reflective
+// em.InitIn, on registries whose view is installed on the provider.
+func TestGeneratedMatchesInit(t *testing.T) {
+	collect := func(t *testing.T, record func(r *em.Registry)) metricdata.ResourceMetrics {
+		reader := m2.NewManualReader()
+		r := em.New(em.WithNamespace("sample_"))
+		r.SetupWithMeter(m2.NewMeterProvider(m2.WithReader(reader), m2.WithView(r.View())).Meter("sample"))
+		record(r)
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		return rm
+	}
+
+	use := func(s *Samplers) {
+		s.Counter.Add(1)
+		s.Gauge.Record(2)
+		s.UpDownCounter.Add(3)
+		s.Histogram.Record(1.5)
+		s.Timer.RecordDuration(time.Second)
+		s.Requests.Add(1)
+		s.PerRoute.Get("/a").Add(1)
+		s.PerRoute.Get("/b").Add(1)
+		s.Workers[1].Processed.Add(1)
+		s.Shards[1].Processed.Add(1)
+		s.Nested.Counter.Add(4)
+		s.Nested.Gauge.Record(5)
+		s.Nested.MoreNest.Counter.Add(6)
+		s.Replica.MoreNest.Counter.Add(6)
+		s.Embedded.Histogram.Record(7)
+		s.Embedded.UpDownCounter.Add(8)
+	}
+	attrs := []attribute.KeyValue{attribute.String("layer", "1")}
+
+	reflective := collect(t, func(r *em.Registry) {
+		use(em.MustInitIn[Samplers](r, attrs...))
+		em.MustInitIn[observed](r, attrs...)
+	})
+
+	generated := collect(t, func(r *em.Registry) {
+		s, err := NewSamplers(r, attrs...)
+		require.NoError(t, err)
+		use(s)
+		_, err = newObserved(r, attrs...)
+		require.NoError(t, err)
+	})
+
+	metricdatatest.AssertEqual(t, reflective, generated, metricdatatest.IgnoreTimestamp())
+
+	found := false
+	for _, m := range generated.ScopeMetrics[0].Metrics {
+		if m.Name == "sample_example_embedded_histogram" {
+			_, found = m.Data.(metricdata.ExponentialHistogram[int64])
+		}
+	}
+	require.True(t, found, "expected sample_example_embedded_histogram to be an exponential histogram")
+}
@@ -0,0 +1,110 @@
+// Code generated by emgen. DO NOT EDIT.
+
+package sample
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/ofeefo/em"
+)
+
+// NewSamplers initializes the instruments of Samplers through r, as em.InitIn would.
+func NewSamplers(r *em.Registry, attrs ...attribute.KeyValue) (*Samplers, error) {
+	s := &Samplers{}
+	ns := r.Namespace()
+	var err error
+
+	if s.Counter, err = em.NewI64Counter(r, em.Spec{ID: ns + "i_am_a_counter"}, attrs...); err != nil {
+		return nil, fmt.Errorf("error initializing field Counter: %w", err)
+	}
+	if s.Gauge, err = em.NewI64Gauge(r, em.Spec{ID: ns + "i_am_a_gauge"}, attrs...); err != nil {
+		return nil, fmt.Errorf("error initializing field Gauge: %w", err)
+	}
+	if s.UpDownCounter, err = em.NewF64UpDownCounter(r, em.Spec{ID: ns + "i_am_a_updowncounter"}, attrs...); err != nil {
+		return nil, fmt.Errorf("error initializing field UpDownCounter: %w", err)
+	}
+	if s.Histogram, err = em.NewF64Histogram(r, em.Spec{ID: ns + "i_am_a_histogram", Description: "A histogram", Unit: "ms", Buckets: []float64{1, 2, 3}}, attrs...); err != nil {
+		return nil, fmt.Errorf("error initializing field Histogram: %w", err)
+	}
+	if s.Timer, err = em.NewTimer(r, em.Spec{ID: ns + "i_am_a_timer"}, attrs...); err != nil {
+		return nil, fmt.Errorf("error initializing field Timer: %w", err)
+	}
+	if s.Requests, err = em.NewI64Counter(r, em.Spec{ID: ns + "i_am_a_request_counter"}, attrs...); err != nil {
+		return nil, fmt.Errorf("error initializing field Requests: %w", err)
+	}
+	base1, err := em.NewI64Counter(r, em.Spec{ID: ns + "i_am_a_family"}, attrs...)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing field PerRoute: %w", err)
+	}
+	if s.PerRoute, err = em.NewFamily[string, em.I64Counter](base1, "route", 1); err != nil {
+		return nil, fmt.Errorf("error initializing field PerRoute: %w", err)
+	}
+	for i1 := range s.Workers {
+		attrs1 := append(attrs[:len(attrs):len(attrs)], attribute.String("pool", "main"), attribute.Int("worker", i1))
+		if s.Workers[i1].Processed, err = em.NewI64Counter(r, em.Spec{ID: ns + "example_worker_processed"}, attrs1...); err != nil {
+			return nil, fmt.Errorf("error initializing field Workers[].Processed: %w", err)
+		}
+	}
+	s.Shards = make([]*worker, 2)
+	for i2 := range s.Shards {
+		attrs2 := append(attrs[:len(attrs):len(attrs)], attribute.Int("index", i2))
+		s.Shards[i2] = &worker{}
+		if s.Shards[i2].Processed, err = em.NewI64Counter(r, em.Spec{ID: ns + "shard_example_worker_processed"}, attrs2...); err != nil {
+			return nil, fmt.Errorf("error initializing field Shards[].Processed: %w", err)
+		}
+	}
+	s.Plain = make([]struct{ Name string }, 2)
+	attrs4 := append(attrs[:len(attrs):len(attrs)], attribute.String("sub", "nested"), attribute.String("gotta", "bar"))
+	if s.Nested.Counter, err = em.NewF64Counter(r, em.Spec{ID: ns + "example_nested_counter"}, attrs4...); err != nil {
+		return nil, fmt.Errorf("error initializing field Nested.Counter: %w", err)
+	}
+	if s.Nested.Gauge, err = em.NewF64Gauge(r, em.Spec{ID: ns + "example_nested_gauge"}, attrs4...); err != nil {
+		return nil, fmt.Errorf("error initializing field Nested.Gauge: %w", err)
+	}
+	attrs5 := append(attrs4[:len(attrs4):len(attrs4)], attribute.String("more", "nest"), attribute.Int64("depth", 2), attribute.Float64("ratio", 0.5), attribute.BoolSlice("flags", []bool{true, false}))
+	if s.Nested.MoreNest.Counter, err = em.NewF64Counter(r, em.Spec{ID: ns + "example_more_nested_counter"}, attrs5...); err != nil {
+		return nil, fmt.Errorf("error initializing field Nested.MoreNest.Counter: %w", err)
+	}
+	s.Replica = &nested{}
+	if s.Replica.Counter, err = em.NewF64Counter(r, em.Spec{ID: ns + "replica_example_nested_counter"}, attrs...); err != nil {
+		return nil, fmt.Errorf("error initializing field Replica.Counter: %w", err)
+	}
+	if s.Replica.Gauge, err = em.NewF64Gauge(r, em.Spec{ID: ns + "replica_example_nested_gauge"}, attrs...); err != nil {
+		return nil, fmt.Errorf("error initializing field Replica.Gauge: %w", err)
+	}
+	attrs6 := append(attrs[:len(attrs):len(attrs)], attribute.String("more", "nest"), attribute.Int64("depth", 2), attribute.Float64("ratio", 0.5), attribute.BoolSlice("flags", []bool{true, false}))
+	if s.Replica.MoreNest.Counter, err = em.NewF64Counter(r, em.Spec{ID: ns + "replica_example_more_nested_counter"}, attrs6...); err != nil {
+		return nil, fmt.Errorf("error initializing field Replica.MoreNest.Counter: %w", err)
+	}
+	s.Embedded = &Embedded{}
+	attrs7 := append(attrs[:len(attrs):len(attrs)], attribute.String("sub", "embedded"), attribute.String("gotta", "bar2"))
+	if s.Embedded.Histogram, err = em.NewI64Histogram(r, em.Spec{ID: ns + "example_embedded_histogram", Exponential: &em.Exponential{MaxSize: 160, MaxScale: 10}}, attrs7...); err != nil {
+		return nil, fmt.Errorf("error initializing field Embedded.Histogram: %w", err)
+	}
+	if s.Embedded.UpDownCounter, err = em.NewF64UpDownCounter(r, em.Spec{ID: ns + "example_embedded_updowncounter"}, attrs7...); err != nil {
+		return nil, fmt.Errorf("error initializing field Embedded.UpDownCounter: %w", err)
+	}
+	return s, nil
+}
+
+// newObserved initializes the instruments of observed through r, as em.InitIn would.
+func newObserved(r *em.Registry, attrs ...attribute.KeyValue) (*observed, error) {
+	s := &observed{}
+	ns := r.Namespace()
+	var err error
+
+	if s.Depth, err = em.NewI64ObservableGauge(r, em.Spec{ID: ns + "queue_depth"}, s.ObserveDepth, attrs...); err != nil {
+		return nil, fmt.Errorf("error initializing field Depth: %w", err)
+	}
+	s.Inner = &inner{}
+	attrs1 := append(attrs[:len(attrs):len(attrs)], attribute.String("inner", "true"))
+	if s.Inner.Size, err = em.NewI64ObservableUpDownCounter(r, em.Spec{ID: ns + "pool_size"}, s.Inner.ObserveSize, attrs1...); err != nil {
+		return nil, fmt.Errorf("error initializing field Inner.Size: %w", err)
+	}
+	if s.Ratio, err = em.NewF64ObservableCounter(r, em.Spec{ID: ns + "ratio"}, s.ObserveRatio, attrs...); err != nil {
+		return nil, fmt.Errorf("error initializing field Ratio: %w", err)
+	}
+	return s, nil
+}
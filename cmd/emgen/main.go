@@ -0,0 +1,63 @@
+// Command emgen generates constructors for em instrument structs, performing
+// the same work as em.Init without reflection. Tags are validated at
+// generation time, so misconfigured structs fail the build instead of the
+// service start.
+//
+// For each requested type T, a function NewT (or newT, for unexported types)
+// with the signature
+//
+//	func NewT(r *em.Registry, attrs ...attribute.KeyValue) (*T, error)
+//
+// is generated, initializing the instruments through r as em.InitIn would. It
+// is meant to be used through go:generate:
+//
+//	//go:generate go run github.com/ofeefo/em/cmd/emgen -type samplers
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	var (
+		types  = flag.String("type", "", "comma-separated list of instrument struct names; required")
+		output = flag.String("output", "", "output file name; defaults to <type>_emgen.go")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: emgen -type T [-output file] [directory]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *types == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	typeNames := strings.Split(*types, ",")
+	if *output == "" {
+		*output = strings.ToLower(typeNames[0]) + "_emgen.go"
+	}
+	out := filepath.Join(dir, *output)
+
+	src, err := generate(dir, filepath.Base(out), typeNames)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "emgen: %s\n", err)
+		os.Exit(1)
+	}
+
+	// nolint: gosec
+	if err = os.WriteFile(out, src, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "emgen: %s\n", err)
+		os.Exit(1)
+	}
+}
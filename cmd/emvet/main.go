@@ -0,0 +1,15 @@
+// Command emvet checks the tags of em instrument structs. It is meant to be
+// run through go vet:
+//
+//	go vet -vettool=$(which emvet) ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/unitchecker"
+
+	"github.com/ofeefo/em/emvet"
+)
+
+func main() {
+	unitchecker.Main(emvet.Analyzer)
+}
@@ -0,0 +1,102 @@
+package em
+
+import (
+	"fmt"
+	"slices"
+
+	"go.opentelemetry.io/otel"
+)
+
+// registration describes how an instrument id was registered, for instruments
+// sharing the id to be checked for conflicts.
+type registration struct {
+	// typeName is the name of the em type of the instrument, such as
+	// I64Counter, capturing both its kind and number type.
+	typeName    string
+	unit        string
+	buckets     []float64
+	exponential *Exponential
+}
+
+func newRegistration(number, kind string, spec Spec) registration {
+	return registration{
+		typeName:    number + "64" + kind,
+		unit:        spec.Unit,
+		buckets:     spec.Buckets,
+		exponential: spec.Exponential,
+	}
+}
+
+func (a registration) equal(b registration) bool {
+	if a.typeName != b.typeName || a.unit != b.unit || !slices.Equal(a.buckets, b.buckets) {
+		return false
+	}
+	if a.exponential == nil || b.exponential == nil {
+		return a.exponential == b.exponential
+	}
+	return *a.exponential == *b.exponential
+}
+
+func (a registration) String() string {
+	s := a.typeName
+	if a.unit != "" {
+		s += fmt.Sprintf(" in unit %q", a.unit)
+	}
+	switch {
+	case a.exponential != nil:
+		s += fmt.Sprintf(" with exponential aggregation (maxsize %d, maxscale %d)", a.exponential.MaxSize, a.exponential.MaxScale)
+	case len(a.buckets) > 0:
+		s += fmt.Sprintf(" with buckets %v", a.buckets)
+	}
+	return s
+}
+
+// WithStrictIDs makes the registry fail to create instruments whose id is
+// already registered with a different kind, number type, unit or bucket
+// layout, or whose Prometheus name is already used by another id. Such
+// conflicts are otherwise reported to the OTEL error handler, and the
+// instrument is created anyway. The default registry is made strict through
+// Configure.
+func WithStrictIDs() Option {
+	return func(r *Registry) {
+		r.strictIDs = true
+	}
+}
+
+// register records the registration of the instrument of the given number
+// type and kind described by spec, checking it against the previous ones of
+// its id and the Prometheus names of the other ids.
+func (r *Registry) register(number, kind string, spec Spec) error {
+	reg := newRegistration(number, kind, spec)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var err error
+	if prev, ok := r.ids[spec.ID]; ok {
+		if prev.equal(reg) {
+			return nil
+		}
+		err = fmt.Errorf("conflicting registrations of instrument %s: %s, previously registered as %s", spec.ID, reg, prev)
+	} else {
+		name := prometheusName(spec.ID, reg.typeName, reg.unit)
+		if other, ok := r.promNames[name]; ok {
+			err = fmt.Errorf("instruments %s and %s are both exported to Prometheus as %s", other, spec.ID, name)
+		}
+		if err == nil || !r.strictIDs {
+			if r.ids == nil {
+				r.ids, r.promNames = map[string]registration{}, map[string]string{}
+			}
+			r.ids[spec.ID] = reg
+			r.promNames[name] = spec.ID
+		}
+	}
+	if err == nil {
+		return nil
+	}
+
+	if r.strictIDs {
+		return err
+	}
+	otel.Handle(err)
+	return nil
+}
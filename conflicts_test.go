@@ -0,0 +1,138 @@
+package em
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+type ordersCounter struct {
+	Orders I64Counter `id:"orders" unit:"{order}"`
+}
+
+func TestConflictingIDs(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Does accept identical registrations", func(t *testing.T) {
+		r := New(WithStrictIDs())
+		MustInitIn[ordersCounter](r)
+		_, err := InitIn[ordersCounter](r)
+		require.NoError(t, err)
+	})
+
+	conflicts := map[string]struct {
+		instruments func(r *Registry) error
+		err         string
+	}{
+		"kind": {
+			instruments: func(r *Registry) error {
+				_, err := InitIn[struct {
+					Orders I64Gauge `id:"orders" unit:"{order}"`
+				}](r)
+				return err
+			},
+			err: `conflicting registrations of instrument orders: I64Gauge in unit "{order}", previously registered as I64Counter in unit "{order}"`,
+		},
+		"number type": {
+			instruments: func(r *Registry) error {
+				_, err := InitIn[struct {
+					Orders F64Counter `id:"orders" unit:"{order}"`
+				}](r)
+				return err
+			},
+			err: `F64Counter in unit "{order}", previously registered as I64Counter`,
+		},
+		"unit": {
+			instruments: func(r *Registry) error {
+				_, err := InitIn[struct {
+					Orders I64Counter `id:"orders"`
+				}](r)
+				return err
+			},
+			err: `I64Counter, previously registered as I64Counter in unit "{order}"`,
+		},
+		"buckets": {
+			instruments: func(r *Registry) error {
+				_, err := NewF64Histogram(r, Spec{ID: "latency", Buckets: []float64{1, 2}})
+				if err != nil {
+					return err
+				}
+				_, err = NewF64Histogram(r, Spec{ID: "latency", Buckets: []float64{1, 3}})
+				return err
+			},
+			err: `F64Histogram with buckets [1 3], previously registered as F64Histogram with buckets [1 2]`,
+		},
+		"aggregation": {
+			instruments: func(r *Registry) error {
+				_, err := NewF64Histogram(r, Spec{ID: "latency", Buckets: []float64{1, 2}})
+				if err != nil {
+					return err
+				}
+				_, err = NewF64Histogram(r, Spec{ID: "latency", Exponential: &Exponential{MaxSize: 160, MaxScale: 20}})
+				return err
+			},
+			err: `F64Histogram with exponential aggregation (maxsize 160, maxscale 20), previously registered as F64Histogram with buckets [1 2]`,
+		},
+		"Prometheus name": {
+			instruments: func(r *Registry) error {
+				_, err := InitIn[struct {
+					Orders I64Counter `id:"orders_total" unit:"{order}"`
+				}](r)
+				return err
+			},
+			err: `instruments orders and orders_total are both exported to Prometheus as orders_total`,
+		},
+	}
+	for name, c := range conflicts {
+		t.Run("Fails in strict mode on conflicting "+name, func(t *testing.T) {
+			r := New(WithStrictIDs())
+			MustInitIn[ordersCounter](r)
+			require.ErrorContains(t, c.instruments(r), c.err)
+		})
+	}
+
+	t.Run("Fails in strict mode set through Configure", func(t *testing.T) {
+		r := New()
+		require.NoError(t, r.Configure(WithStrictIDs()))
+		MustInitIn[ordersCounter](r)
+		require.ErrorContains(t, conflicts["kind"].instruments(r), conflicts["kind"].err)
+	})
+
+	t.Run("Does report conflicts with their field path", func(t *testing.T) {
+		type instruments struct {
+			Shop struct {
+				Orders I64UpDownCounter `id:"orders"`
+			}
+		}
+		r := New(WithStrictIDs())
+		MustInitIn[ordersCounter](r)
+		_, err := InitIn[instruments](r)
+
+		var fieldErr *FieldError
+		require.ErrorAs(t, err, &fieldErr)
+		require.Equal(t, "Shop.Orders", fieldErr.Path)
+	})
+}
+
+// TestLenientIDs replaces the global OTEL error handler, so it can't run in
+// parallel.
+func TestLenientIDs(t *testing.T) {
+	var handled []error
+	prev := otel.GetErrorHandler()
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		handled = append(handled, err)
+	}))
+	t.Cleanup(func() { otel.SetErrorHandler(prev) })
+
+	r := New()
+	MustInitIn[ordersCounter](r)
+	s, err := InitIn[struct {
+		Orders I64Gauge `id:"orders"`
+	}](r)
+	require.NoError(t, err)
+	require.NotNil(t, s.Orders)
+
+	require.Len(t, handled, 1)
+	require.ErrorContains(t, handled[0], "conflicting registrations of instrument orders")
+}
@@ -0,0 +1,181 @@
+package em
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Spec describes a single instrument, as declared through the tags of an
+// instruments struct field. The constructors in this file create instruments
+// from a Spec without reflection, and are mainly used by code generated with
+// cmd/emgen.
+type Spec struct {
+	// ID is the instrument identifier ('id' tag).
+	ID string
+	// Buckets are the explicit bucket boundaries of histograms ('buckets' tag).
+	Buckets []float64
+	// Description is the instrument description ('desc' tag).
+	Description string
+	// Unit is the unit of measurements, such as "ms" or "By" ('unit' tag).
+	Unit string
+	// Exponential makes histograms use the base2 exponential aggregation
+	// instead of explicit buckets ('aggregation' tag).
+	Exponential *Exponential
+}
+
+// Exponential configures the base2 exponential histogram aggregation. Its
+// fields are used as is, while ParseAggregation fills in the OTEL defaults for
+// omitted tags.
+type Exponential struct {
+	// MaxSize is the maximum number of buckets for each of the positive and
+	// negative ranges ('maxsize' tag).
+	MaxSize int32 `json:"maxSize" yaml:"maxSize"`
+	// MaxScale is the maximum resolution scale, from -10 to 20 ('maxscale'
+	// tag).
+	MaxScale int32 `json:"maxScale" yaml:"maxScale"`
+}
+
+func (e *Exponential) validate() error {
+	if e.MaxSize <= 0 {
+		return fmt.Errorf("maxsize must be positive, got %d", e.MaxSize)
+	}
+	if e.MaxScale < -10 || e.MaxScale > 20 {
+		return fmt.Errorf("maxscale must be between -10 and 20, got %d", e.MaxScale)
+	}
+	return nil
+}
+
+func (s Spec) validate() error {
+	if s.ID == "" {
+		return fmt.Errorf("missing id for instrument")
+	}
+	if err := ValidateID(s.ID); err != nil {
+		return err
+	}
+	if s.Exponential == nil {
+		return nil
+	}
+	if len(s.Buckets) > 0 {
+		return fmt.Errorf("buckets cannot be used with the exponential aggregation for instrument %s", s.ID)
+	}
+	if err := s.Exponential.validate(); err != nil {
+		return fmt.Errorf("invalid exponential aggregation for instrument %s: %s", s.ID, err)
+	}
+	return nil
+}
+
+func (s Spec) description() metric.InstrumentOption {
+	return metric.WithDescription(s.Description)
+}
+
+func (s Spec) unit() metric.InstrumentOption {
+	return metric.WithUnit(s.Unit)
+}
+
+func NewI64Counter(r *Registry, spec Spec, attrs ...attribute.KeyValue) (I64Counter, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return r.i64c(counter, spec, attrs...)
+}
+
+func NewI64UpDownCounter(r *Registry, spec Spec, attrs ...attribute.KeyValue) (I64UpDownCounter, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return r.i64c(upDownCounter, spec, attrs...)
+}
+
+func NewF64Counter(r *Registry, spec Spec, attrs ...attribute.KeyValue) (F64Counter, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return r.f64c(counter, spec, attrs...)
+}
+
+func NewF64UpDownCounter(r *Registry, spec Spec, attrs ...attribute.KeyValue) (F64UpDownCounter, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return r.f64c(upDownCounter, spec, attrs...)
+}
+
+func NewI64Gauge(r *Registry, spec Spec, attrs ...attribute.KeyValue) (I64Gauge, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return r.i64r(gauge, spec, attrs...)
+}
+
+func NewI64Histogram(r *Registry, spec Spec, attrs ...attribute.KeyValue) (I64Histogram, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return r.i64r(histogram, spec, attrs...)
+}
+
+func NewF64Gauge(r *Registry, spec Spec, attrs ...attribute.KeyValue) (F64Gauge, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return r.f64r(gauge, spec, attrs...)
+}
+
+func NewF64Histogram(r *Registry, spec Spec, attrs ...attribute.KeyValue) (F64Histogram, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return r.f64r(histogram, spec, attrs...)
+}
+
+func NewTimer(r *Registry, spec Spec, attrs ...attribute.KeyValue) (Timer, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return r.timer(spec, attrs...)
+}
+
+func NewI64ObservableCounter(r *Registry, spec Spec, cb func(context.Context, I64Observer) error, attrs ...attribute.KeyValue) (I64ObservableCounter, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return r.i64o(observableCounter, spec, cb, attrs...)
+}
+
+func NewI64ObservableUpDownCounter(r *Registry, spec Spec, cb func(context.Context, I64Observer) error, attrs ...attribute.KeyValue) (I64ObservableUpDownCounter, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return r.i64o(observableUpDownCounter, spec, cb, attrs...)
+}
+
+func NewI64ObservableGauge(r *Registry, spec Spec, cb func(context.Context, I64Observer) error, attrs ...attribute.KeyValue) (I64ObservableGauge, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return r.i64o(observableGauge, spec, cb, attrs...)
+}
+
+func NewF64ObservableCounter(r *Registry, spec Spec, cb func(context.Context, F64Observer) error, attrs ...attribute.KeyValue) (F64ObservableCounter, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return r.f64o(observableCounter, spec, cb, attrs...)
+}
+
+func NewF64ObservableUpDownCounter(r *Registry, spec Spec, cb func(context.Context, F64Observer) error, attrs ...attribute.KeyValue) (F64ObservableUpDownCounter, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return r.f64o(observableUpDownCounter, spec, cb, attrs...)
+}
+
+func NewF64ObservableGauge(r *Registry, spec Spec, cb func(context.Context, F64Observer) error, attrs ...attribute.KeyValue) (F64ObservableGauge, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return r.f64o(observableGauge, spec, cb, attrs...)
+}
@@ -0,0 +1,260 @@
+// Package emtest provides helpers to unit test instruments initialized by em
+// without scraping an exporter. Setup returns a fresh registry backed by an
+// in-memory reader, whose assertion helpers read measurements back by
+// instrument id. SetupRegistry installs such a reader on an existing
+// registry, such as the default one used by em.Init.
+package emtest
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	m2 "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/ofeefo/em"
+)
+
+// Reader collects the measurements recorded by the instruments of a registry.
+type Reader struct {
+	r *m2.ManualReader
+}
+
+// Setup returns a new registry, created with opts, recording into the
+// returned in-memory reader. Instruments must be initialized through it with
+// em.InitIn, so that tests, including parallel ones, never observe each
+// other's measurements. The registry is shut down once the test finishes,
+// unregistering the callbacks of its observable instruments.
+func Setup(t testing.TB, opts ...em.Option) (*em.Registry, *Reader) {
+	t.Helper()
+	registry := em.New(opts...)
+	reader := m2.NewManualReader()
+	if err := registry.SetupWith("emtest", em.WithReader(reader)); err != nil {
+		t.Fatalf("emtest: failed setting up registry: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := registry.Shutdown(context.Background()); err != nil {
+			t.Errorf("emtest: failed shutting down registry: %s", err)
+		}
+	})
+	return registry, &Reader{r: reader}
+}
+
+var current atomic.Pointer[Reader]
+
+// SetupRegistry installs an in-memory reader on registry, usually em.Default(),
+// the one used by em.Init and em.MustInit. Any provider previously set up on it
+// is shut down, and the registry is shut down again once the test finishes, so
+// every test starts from empty measurements. Tests sharing a registry can't run
+// in parallel. The package-level assertion helpers read from the reader
+// installed by the last call to SetupRegistry.
+func SetupRegistry(t testing.TB, registry *em.Registry) *Reader {
+	t.Helper()
+	if err := registry.Shutdown(context.Background()); err != nil {
+		t.Fatalf("emtest: failed shutting down previous provider: %s", err)
+	}
+
+	reader := m2.NewManualReader()
+	if err := registry.SetupWith("emtest", em.WithReader(reader)); err != nil {
+		t.Fatalf("emtest: failed setting up registry: %s", err)
+	}
+
+	r := &Reader{r: reader}
+	current.Store(r)
+	t.Cleanup(func() {
+		current.CompareAndSwap(r, nil)
+		if err := registry.Shutdown(context.Background()); err != nil {
+			t.Errorf("emtest: failed shutting down registry: %s", err)
+		}
+	})
+	return r
+}
+
+// CounterValue returns the sum of the values of the counter (or up-down
+// counter) identified by id across all series having attrs, as recorded by
+// the reader installed by SetupRegistry.
+func CounterValue(t testing.TB, id string, attrs ...attribute.KeyValue) float64 {
+	t.Helper()
+	return mustCurrent(t).CounterValue(t, id, attrs...)
+}
+
+// HistogramCount returns the number of measurements recorded by the histogram
+// identified by id across all series having attrs, as recorded by the reader
+// installed by SetupRegistry.
+func HistogramCount(t testing.TB, id string, attrs ...attribute.KeyValue) uint64 {
+	t.Helper()
+	return mustCurrent(t).HistogramCount(t, id, attrs...)
+}
+
+// GaugeLast returns the most recent value of the gauge identified by id among
+// the series having attrs, as recorded by the reader installed by
+// SetupRegistry.
+func GaugeLast(t testing.TB, id string, attrs ...attribute.KeyValue) float64 {
+	t.Helper()
+	return mustCurrent(t).GaugeLast(t, id, attrs...)
+}
+
+// AssertAttrs fails the test unless the instrument identified by id has a
+// series with all the provided attributes in the reader installed by
+// SetupRegistry.
+func AssertAttrs(t testing.TB, id string, attrs ...attribute.KeyValue) {
+	t.Helper()
+	mustCurrent(t).AssertAttrs(t, id, attrs...)
+}
+
+func mustCurrent(t testing.TB) *Reader {
+	t.Helper()
+	r := current.Load()
+	if r == nil {
+		t.Fatalf("emtest: SetupRegistry must be called before using package-level assertions")
+	}
+	return r
+}
+
+// CounterValue returns the sum of the values of the counter (or up-down
+// counter) identified by id across all series having attrs.
+func (r *Reader) CounterValue(t testing.TB, id string, attrs ...attribute.KeyValue) float64 {
+	t.Helper()
+	var res float64
+	for _, p := range r.points(t, id, attrs) {
+		res += p.value
+	}
+	return res
+}
+
+// HistogramCount returns the number of measurements recorded by the histogram
+// identified by id across all series having attrs.
+func (r *Reader) HistogramCount(t testing.TB, id string, attrs ...attribute.KeyValue) uint64 {
+	t.Helper()
+	var res uint64
+	for _, p := range r.points(t, id, attrs) {
+		res += p.count
+	}
+	return res
+}
+
+// GaugeLast returns the most recent value of the gauge identified by id among
+// the series having attrs.
+func (r *Reader) GaugeLast(t testing.TB, id string, attrs ...attribute.KeyValue) float64 {
+	t.Helper()
+	var last point
+	for _, p := range r.points(t, id, attrs) {
+		if !p.time.Before(last.time) {
+			last = p
+		}
+	}
+	return last.value
+}
+
+// AssertAttrs fails the test unless the instrument identified by id has a
+// series with all the provided attributes.
+func (r *Reader) AssertAttrs(t testing.TB, id string, attrs ...attribute.KeyValue) {
+	t.Helper()
+	r.points(t, id, attrs)
+}
+
+// Collect returns everything recorded so far.
+func (r *Reader) Collect(t testing.TB) metricdata.ResourceMetrics {
+	t.Helper()
+	rm := metricdata.ResourceMetrics{}
+	if err := r.r.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("emtest: failed collecting metrics: %s", err)
+	}
+	return rm
+}
+
+type point struct {
+	attrs attribute.Set
+	value float64
+	count uint64
+	time  time.Time
+}
+
+// points returns the data points of id having attrs, failing the test if
+// there are none.
+func (r *Reader) points(t testing.TB, id string, attrs []attribute.KeyValue) []point {
+	t.Helper()
+	found := false
+	res := []point{}
+	for _, sm := range r.Collect(t).ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != id {
+				continue
+			}
+			found = true
+			for _, p := range pointsOf(m.Data) {
+				if hasAttrs(p.attrs, attrs) {
+					res = append(res, p)
+				}
+			}
+		}
+	}
+
+	if !found {
+		t.Fatalf("emtest: no measurements found for %s", id)
+	}
+	if len(res) == 0 {
+		t.Fatalf("emtest: no series of %s has attributes %v", id, attrs)
+	}
+	return res
+}
+
+func hasAttrs(set attribute.Set, attrs []attribute.KeyValue) bool {
+	for _, a := range attrs {
+		v, ok := set.Value(a.Key)
+		if !ok || v != a.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func pointsOf(data metricdata.Aggregation) []point {
+	switch d := data.(type) {
+	case metricdata.Sum[int64]:
+		return dataPoints(d.DataPoints)
+	case metricdata.Sum[float64]:
+		return dataPoints(d.DataPoints)
+	case metricdata.Gauge[int64]:
+		return dataPoints(d.DataPoints)
+	case metricdata.Gauge[float64]:
+		return dataPoints(d.DataPoints)
+	case metricdata.Histogram[int64]:
+		return histogramPoints(d.DataPoints)
+	case metricdata.Histogram[float64]:
+		return histogramPoints(d.DataPoints)
+	case metricdata.ExponentialHistogram[int64]:
+		return expHistogramPoints(d.DataPoints)
+	case metricdata.ExponentialHistogram[float64]:
+		return expHistogramPoints(d.DataPoints)
+	}
+	return nil
+}
+
+func dataPoints[N int64 | float64](dps []metricdata.DataPoint[N]) []point {
+	res := make([]point, 0, len(dps))
+	for _, dp := range dps {
+		res = append(res, point{attrs: dp.Attributes, value: float64(dp.Value), time: dp.Time})
+	}
+	return res
+}
+
+func histogramPoints[N int64 | float64](dps []metricdata.HistogramDataPoint[N]) []point {
+	res := make([]point, 0, len(dps))
+	for _, dp := range dps {
+		res = append(res, point{attrs: dp.Attributes, value: float64(dp.Sum), count: dp.Count, time: dp.Time})
+	}
+	return res
+}
+
+func expHistogramPoints[N int64 | float64](dps []metricdata.ExponentialHistogramDataPoint[N]) []point {
+	res := make([]point, 0, len(dps))
+	for _, dp := range dps {
+		res = append(res, point{attrs: dp.Attributes, value: float64(dp.Sum), count: dp.Count, time: dp.Time})
+	}
+	return res
+}
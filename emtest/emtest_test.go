@@ -0,0 +1,97 @@
+package emtest_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/ofeefo/em"
+	"github.com/ofeefo/em/emtest"
+)
+
+type instruments struct {
+	Counter   em.I64Counter   `id:"emtest_counter"`
+	Gauge     em.F64Gauge     `id:"emtest_gauge"`
+	Histogram em.F64Histogram `id:"emtest_histogram" buckets:"1,2,3"`
+	Nested    struct {
+		Counter em.F64Counter `id:"emtest_nested_counter"`
+	} `attrs:"sub,nested"`
+}
+
+func TestAssertions(t *testing.T) {
+	t.Parallel()
+	r, reader := emtest.Setup(t)
+
+	s := em.MustInitIn[instruments](r, attribute.String("layer", "1"))
+	s.Counter.Add(2, em.Attrs(attribute.String("route", "a")))
+	s.Counter.Add(3, em.Attrs(attribute.String("route", "b")))
+	s.Gauge.Record(1)
+	s.Gauge.Record(7)
+	s.Histogram.Record(1.5)
+	s.Histogram.Record(2.5)
+	s.Nested.Counter.Add(0.5)
+
+	require.Equal(t, float64(5), reader.CounterValue(t, "emtest_counter"))
+	require.Equal(t, float64(2), reader.CounterValue(t, "emtest_counter", attribute.String("route", "a")))
+	require.Equal(t, float64(7), reader.GaugeLast(t, "emtest_gauge"))
+	require.Equal(t, uint64(2), reader.HistogramCount(t, "emtest_histogram", attribute.String("layer", "1")))
+	reader.AssertAttrs(t, "emtest_nested_counter", attribute.String("layer", "1"), attribute.String("sub", "nested"))
+}
+
+type observed struct {
+	Gauge em.I64ObservableGauge `id:"emtest_observed" callback:"Observe"`
+	value int64
+}
+
+func (o *observed) Observe(_ context.Context, obs em.I64Observer) error {
+	obs.Observe(o.value)
+	return nil
+}
+
+func TestIsolation(t *testing.T) {
+	t.Parallel()
+
+	for _, value := range []int64{42, 7} {
+		t.Run(fmt.Sprintf("Does only report the instruments of the test (%d)", value), func(t *testing.T) {
+			t.Parallel()
+			r, reader := emtest.Setup(t)
+
+			s := em.MustInitIn[observed](r)
+			s.value = value
+			require.Equal(t, float64(value), reader.GaugeLast(t, "emtest_observed"))
+
+			rm := reader.Collect(t)
+			require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+			require.Len(t, rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Gauge[int64]).DataPoints, 1)
+		})
+	}
+}
+
+func TestSetupRegistry(t *testing.T) {
+	t.Run("Does install the reader on the default registry", func(t *testing.T) {
+		emtest.SetupRegistry(t, em.Default())
+
+		s := em.MustInit[instruments](attribute.String("layer", "1"))
+		s.Counter.Add(2, em.Attrs(attribute.String("route", "a")))
+		s.Gauge.Record(7)
+		s.Histogram.Record(1.5)
+		s.Nested.Counter.Add(0.5)
+
+		require.Equal(t, float64(2), emtest.CounterValue(t, "emtest_counter", attribute.String("route", "a")))
+		require.Equal(t, float64(7), emtest.GaugeLast(t, "emtest_gauge"))
+		require.Equal(t, uint64(1), emtest.HistogramCount(t, "emtest_histogram"))
+		emtest.AssertAttrs(t, "emtest_nested_counter", attribute.String("layer", "1"), attribute.String("sub", "nested"))
+	})
+
+	t.Run("Does start from empty measurements", func(t *testing.T) {
+		emtest.SetupRegistry(t, em.Default())
+
+		s := em.MustInit[instruments]()
+		s.Counter.Add(1)
+		require.Equal(t, float64(1), emtest.CounterValue(t, "emtest_counter"))
+	})
+}
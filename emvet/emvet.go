@@ -0,0 +1,502 @@
+// Package emvet defines an Analyzer reporting misconfigured em instrument
+// structs, which em.Init would otherwise only report at runtime (or, for
+// unexported fields, not at all).
+package emvet
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+
+	"github.com/ofeefo/em"
+	"github.com/ofeefo/em/internal/instrument"
+)
+
+const doc = `check em instrument struct tags
+
+The emvet analyzer reports instrument fields missing the 'id' tag (or, for
+observables, the 'callback' tag), ids that are not valid OTEL instrument
+names, unparsable 'buckets', 'buckets' or 'aggregation' on non-histogram
+fields, invalid 'aggregation', 'maxsize' and 'maxscale', 'buckets' on
+exponential histograms, timer units other than s or ms, odd-length 'attrs',
+unexported instrument fields that em.Init skips, invalid 'kind' tags and
+types defined on top of em ones missing them, em.Family fields missing the
+'key' tag or holding observables, slices of instrument structs missing the
+'len' tag, invalid 'len' tags, and instruments sharing an id (including
+prefixes) and attributes within a struct.
+
+Only structs with fields carrying em tags, those initialized or described
+through em, and the structs they nest are checked.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "emvet",
+	Doc:      doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// tags are the keys of the struct tags read by em.
+var tags = []string{
+	"id", "buckets", "attrs", "callback", "kind", "key", "maxkeys", "len",
+	"index", "prefix", "desc", "unit", "aggregation", "maxsize", "maxscale",
+}
+
+// initializers are the em functions walking the struct given as type argument.
+var initializers = map[string]bool{
+	"Init":       true,
+	"InitIn":     true,
+	"MustInit":   true,
+	"MustInitIn": true,
+	"Describe":   true,
+	"DescribeIn": true,
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	checked := instrumentStructs(pass, insp)
+
+	nodes := []ast.Node{(*ast.StructType)(nil), (*ast.TypeSpec)(nil)}
+	insp.Preorder(nodes, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.StructType:
+			if st, ok := pass.TypesInfo.TypeOf(n).(*types.Struct); ok && checked[st] {
+				checkFields(pass, n)
+			}
+		case *ast.TypeSpec:
+			checkDuplicates(pass, n)
+		}
+	})
+	return nil, nil
+}
+
+// instrumentStructs returns the structs meant to be initialized by em, whose
+// fields are checked: those with fields carrying em tags, the type arguments of
+// the em functions initializing structs, and the structs they nest. Others may
+// hold instruments for unrelated purposes, such as handlers recording into
+// instruments initialized elsewhere.
+func instrumentStructs(pass *analysis.Pass, insp *inspector.Inspector) map[*types.Struct]bool {
+	var roots []*types.Struct
+	insp.Preorder([]ast.Node{(*ast.StructType)(nil)}, func(n ast.Node) {
+		st, ok := pass.TypesInfo.TypeOf(n.(*ast.StructType)).(*types.Struct)
+		if ok && hasTags(st) {
+			roots = append(roots, st)
+		}
+	})
+
+	for id, inst := range pass.TypesInfo.Instances {
+		fn, ok := pass.TypesInfo.Uses[id].(*types.Func)
+		if !ok || fn.Pkg() == nil || fn.Pkg().Path() != instrument.Path || !initializers[fn.Name()] || inst.TypeArgs.Len() != 1 {
+			continue
+		}
+		if st, ok := nestedStruct(inst.TypeArgs.At(0)); ok {
+			roots = append(roots, st)
+		}
+	}
+
+	checked := map[*types.Struct]bool{}
+	var add func(st *types.Struct)
+	add = func(st *types.Struct) {
+		if checked[st] {
+			return
+		}
+		checked[st] = true
+		for i := 0; i < st.NumFields(); i++ {
+			f := st.Field(i)
+			if !f.Exported() {
+				continue
+			}
+			if inner, _, ok := elementStruct(f.Type()); ok {
+				add(inner)
+			} else if inner, ok := nestedStruct(f.Type()); ok {
+				add(inner)
+			}
+		}
+	}
+	for _, st := range roots {
+		add(st)
+	}
+	return checked
+}
+
+// hasTags tells whether any field of st carries an em tag.
+func hasTags(st *types.Struct) bool {
+	for i := 0; i < st.NumFields(); i++ {
+		tag := reflect.StructTag(st.Tag(i))
+		for _, key := range tags {
+			if _, ok := tag.Lookup(key); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// instrumentTypes returns the names of the em instrument types a field of type
+// t may be initialized as: the type itself, or those sharing its method set
+// for types defined on top of em ones, which em.Init tells apart through the
+// 'kind' tag.
+func instrumentTypes(pass *analysis.Pass, t types.Type) []string {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return nil
+	}
+	obj := named.Obj()
+	if obj.Pkg() != nil && obj.Pkg().Path() == instrument.Path {
+		if _, ok = instrument.Types[obj.Name()]; ok {
+			return []string{obj.Name()}
+		}
+		return nil
+	}
+
+	if _, ok = named.Underlying().(*types.Interface); !ok {
+		return nil
+	}
+	var names []string
+	for _, imp := range pass.Pkg.Imports() {
+		if imp.Path() != instrument.Path {
+			continue
+		}
+		for name := range instrument.Types {
+			if emObj := imp.Scope().Lookup(name); emObj != nil && types.Identical(named.Underlying(), emObj.Type().Underlying()) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// familyInstrument returns the instrument type of t if it is an em.Family.
+func familyInstrument(t types.Type) (types.Type, bool) {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return nil, false
+	}
+	obj := named.Obj()
+	if obj.Pkg() == nil || obj.Pkg().Path() != instrument.Path || obj.Name() != "Family" || named.TypeArgs().Len() != 2 {
+		return nil, false
+	}
+	return named.TypeArgs().At(1), true
+}
+
+// nestedStruct returns the struct type em.Init recurses into for a field of
+// type t, if any.
+func nestedStruct(t types.Type) (*types.Struct, bool) {
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	return st, ok
+}
+
+// elementStruct returns the struct type of the elements of an array or slice
+// field of type t, which em.Init initializes as nested structs, and whether t
+// is a slice.
+func elementStruct(t types.Type) (*types.Struct, bool, bool) {
+	var elem types.Type
+	slice := false
+	switch u := t.Underlying().(type) {
+	case *types.Array:
+		elem = u.Elem()
+	case *types.Slice:
+		elem, slice = u.Elem(), true
+	default:
+		return nil, false, false
+	}
+	st, ok := nestedStruct(elem)
+	return st, slice, ok
+}
+
+// hasInstruments tells whether em.Init would initialize any instrument within
+// st.
+func hasInstruments(pass *analysis.Pass, st *types.Struct) bool {
+	found := false
+	walk(pass, st, "", "", "", map[*types.Struct]bool{}, func(string, string, string) {
+		found = true
+	})
+	return found
+}
+
+func checkFields(pass *analysis.Pass, st *ast.StructType) {
+	for _, field := range st.Fields.List {
+		t := pass.TypesInfo.TypeOf(field.Type)
+		if t == nil {
+			continue
+		}
+
+		tag := reflect.StructTag("")
+		if field.Tag != nil {
+			raw, _ := strconv.Unquote(field.Tag.Value)
+			tag = reflect.StructTag(raw)
+		}
+
+		for _, name := range fieldNames(field) {
+			if inst, ok := familyInstrument(t); ok {
+				checkFamily(pass, field, name, tag, inst)
+				continue
+			}
+
+			if names := instrumentTypes(pass, t); len(names) > 0 {
+				checkInstrument(pass, field, name, tag, names)
+				continue
+			}
+
+			if elem, slice, ok := elementStruct(t); ok && ast.IsExported(name.Name) {
+				checkElements(pass, field, name, tag, elem, slice)
+				continue
+			}
+
+			if _, ok := nestedStruct(t); ok && ast.IsExported(name.Name) {
+				if _, err := em.ParseAttrs(tag.Get("attrs")); err != nil {
+					pass.Reportf(field.Pos(), "invalid attrs tag on field %s: %s", name.Name, err)
+				}
+			}
+		}
+	}
+}
+
+func checkElements(pass *analysis.Pass, field *ast.Field, name *ast.Ident, tag reflect.StructTag, elem *types.Struct, slice bool) {
+	if !hasInstruments(pass, elem) {
+		return
+	}
+
+	if raw, ok := tag.Lookup("len"); ok {
+		if n, err := strconv.Atoi(strings.TrimSpace(raw)); err != nil || n < 0 {
+			pass.Reportf(field.Pos(), "invalid len tag on field %s: %q", name.Name, raw)
+		}
+	} else if slice {
+		pass.Reportf(field.Pos(), "slice field %s of instrument structs is missing the len tag", name.Name)
+	}
+
+	if _, err := em.ParseAttrs(tag.Get("attrs")); err != nil {
+		pass.Reportf(field.Pos(), "invalid attrs tag on field %s: %s", name.Name, err)
+	}
+}
+
+func checkInstrument(pass *analysis.Pass, field *ast.Field, name *ast.Ident, tag reflect.StructTag, candidates []string) {
+	if !ast.IsExported(name.Name) {
+		pass.Reportf(name.Pos(), "instrument field %s is unexported and will not be initialized by em", name.Name)
+		return
+	}
+
+	res, err := instrument.Resolve(candidates, tag.Get("kind"))
+	if err != nil {
+		pass.Reportf(field.Pos(), "invalid kind tag on field %s: %s", name.Name, err)
+		return
+	}
+	info := instrument.Types[res[0]]
+	// The number type of observables is picked from their callback.
+	if len(res) > 1 && !(info.Observable && len(res) == 2) {
+		pass.Reportf(field.Pos(), "ambiguous kind for field %s, which could be any of %s: set the kind tag", name.Name, strings.Join(res, ", "))
+		return
+	}
+
+	if id := tag.Get("id"); id == "" {
+		pass.Reportf(field.Pos(), "missing id tag for field %s", name.Name)
+	} else if err := em.ValidateID(id); err != nil {
+		pass.Reportf(field.Pos(), "invalid id tag on field %s: %s", name.Name, err)
+	}
+
+	if info.Observable && tag.Get("callback") == "" {
+		pass.Reportf(field.Pos(), "missing callback tag for field %s", name.Name)
+	}
+
+	if unit := tag.Get("unit"); info.Timer && unit != "" && unit != "s" && unit != "ms" {
+		pass.Reportf(field.Pos(), "unsupported unit %q on timer field %s, expected s or ms", unit, name.Name)
+	}
+
+	buckets, hasBuckets := tag.Lookup("buckets")
+	switch {
+	case hasBuckets && !info.Histogram:
+		pass.Reportf(field.Pos(), "buckets tag on non-histogram field %s", name.Name)
+	case hasBuckets:
+		if _, err := em.ParseBuckets(buckets); err != nil {
+			pass.Reportf(field.Pos(), "invalid buckets tag on field %s: %s", name.Name, err)
+		}
+	}
+
+	aggregation, maxSize, maxScale := tag.Get("aggregation"), tag.Get("maxsize"), tag.Get("maxscale")
+	if aggregation == "" && maxSize == "" && maxScale == "" {
+		return
+	}
+	if !info.Histogram {
+		pass.Reportf(field.Pos(), "aggregation tags on non-histogram field %s", name.Name)
+		return
+	}
+	exp, err := em.ParseAggregation(aggregation, maxSize, maxScale)
+	switch {
+	case err != nil:
+		pass.Reportf(field.Pos(), "invalid aggregation tags on field %s: %s", name.Name, err)
+	case exp != nil && hasBuckets:
+		pass.Reportf(field.Pos(), "buckets tag on exponential histogram field %s", name.Name)
+	}
+}
+
+func checkFamily(pass *analysis.Pass, field *ast.Field, name *ast.Ident, tag reflect.StructTag, inst types.Type) {
+	names := instrumentTypes(pass, inst)
+	switch {
+	case !ast.IsExported(name.Name):
+		pass.Reportf(name.Pos(), "instrument field %s is unexported and will not be initialized by em", name.Name)
+		return
+	case len(names) == 0:
+		pass.Reportf(field.Pos(), "unsupported instrument type %s for family field %s", inst, name.Name)
+		return
+	case instrument.Types[names[0]].Observable:
+		pass.Reportf(field.Pos(), "observable instruments are not supported by families, for field %s", name.Name)
+		return
+	}
+
+	if tag.Get("key") == "" {
+		pass.Reportf(field.Pos(), "missing key tag for field %s", name.Name)
+	}
+	if raw := tag.Get("maxkeys"); raw != "" {
+		if maxKeys, err := strconv.Atoi(strings.TrimSpace(raw)); err != nil || maxKeys < 0 {
+			pass.Reportf(field.Pos(), "invalid maxkeys tag on field %s", name.Name)
+		}
+	}
+	checkInstrument(pass, field, name, tag, names)
+}
+
+// occurrence records where an id was found while walking a struct tree.
+type occurrence struct {
+	path  string
+	attrs string
+}
+
+// checkDuplicates reports instruments sharing both their id and static
+// attributes within the tree of the named struct declared by ts, as they
+// would be recorded into the same series.
+func checkDuplicates(pass *analysis.Pass, ts *ast.TypeSpec) {
+	obj := pass.TypesInfo.Defs[ts.Name]
+	if obj == nil {
+		return
+	}
+	st, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return
+	}
+
+	seen := map[string]occurrence{}
+	var dups []string
+	walk(pass, st, "", "", "", map[*types.Struct]bool{}, func(id, path, attrs string) {
+		prev, ok := seen[id]
+		if !ok {
+			seen[id] = occurrence{path, attrs}
+			return
+		}
+		if prev.attrs == attrs && !reportedElsewhere(pass, st, prev.path, path) {
+			dups = append(dups, "id "+id+" is used by fields "+prev.path+" and "+path)
+		}
+	})
+
+	sort.Strings(dups)
+	for _, d := range dups {
+		pass.Reportf(ts.Name.Pos(), "duplicate instrument in %s: %s", ts.Name.Name, d)
+	}
+}
+
+// walk calls fn for every instrument em.Init would initialize within st, with
+// its id (prepended with prefix and the 'prefix' tags of enclosing fields),
+// dotted field path and static attributes.
+func walk(pass *analysis.Pass, st *types.Struct, prefix, path, attrs string, visiting map[*types.Struct]bool, fn func(id, path, attrs string)) {
+	if visiting[st] {
+		return
+	}
+	visiting[st] = true
+	defer delete(visiting, st)
+
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if !f.Exported() {
+			continue
+		}
+
+		tag := reflect.StructTag(st.Tag(i))
+		fPath := f.Name()
+		if path != "" {
+			fPath = path + "." + f.Name()
+		}
+
+		_, family := familyInstrument(f.Type())
+		if family || len(instrumentTypes(pass, f.Type())) > 0 {
+			if id := tag.Get("id"); id != "" {
+				fn(prefix+id, fPath, attrs)
+			}
+			continue
+		}
+
+		if inner, slice, ok := elementStruct(f.Type()); ok {
+			if slice && tag.Get("len") == "" {
+				continue
+			}
+			key := tag.Get("index")
+			if key == "" {
+				key = "index"
+			}
+			innerAttrs := attrs
+			if a := tag.Get("attrs"); a != "" {
+				innerAttrs += "," + a
+			}
+			walk(pass, inner, prefix+tag.Get("prefix"), fPath+"[]", innerAttrs+","+key+",[]", visiting, fn)
+			continue
+		}
+
+		if inner, ok := nestedStruct(f.Type()); ok {
+			innerAttrs := attrs
+			if a := tag.Get("attrs"); a != "" {
+				innerAttrs += "," + a
+			}
+			walk(pass, inner, prefix+tag.Get("prefix"), fPath, innerAttrs, visiting, fn)
+		}
+	}
+}
+
+// reportedElsewhere tells whether both paths are within the same field whose
+// type is a named struct of the analyzed package, which is reported on its
+// own declaration.
+func reportedElsewhere(pass *analysis.Pass, st *types.Struct, a, b string) bool {
+	first := strings.Split(a, ".")[0]
+	if first != strings.Split(b, ".")[0] || !strings.Contains(a, ".") || !strings.Contains(b, ".") {
+		return false
+	}
+
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if f.Name() != first {
+			continue
+		}
+		t := f.Type()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		named, ok := t.(*types.Named)
+		return ok && named.Obj().Pkg() == pass.Pkg
+	}
+	return false
+}
+
+func fieldNames(field *ast.Field) []*ast.Ident {
+	if len(field.Names) > 0 {
+		return field.Names
+	}
+
+	// Embedded fields are named after their type.
+	expr := field.Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return []*ast.Ident{t}
+	case *ast.SelectorExpr:
+		return []*ast.Ident{t.Sel}
+	}
+	return nil
+}
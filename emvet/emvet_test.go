@@ -0,0 +1,13 @@
+package emvet
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	// The testdata package is loaded from the em module, so that it can
+	// import em.
+	analysistest.Run(t, "..", Analyzer, "./emvet/testdata/a")
+}
@@ -0,0 +1,91 @@
+package a
+
+import (
+	"context"
+
+	"github.com/ofeefo/em"
+)
+
+type valid struct {
+	Counter   em.I64Counter                    `id:"valid_counter"`
+	Histogram em.F64Histogram                  `id:"valid_histogram" buckets:"1,2,3"`
+	Latency   em.F64Histogram                  `id:"valid_latency" aggregation:"exponential" maxsize:"80"`
+	Duration  em.Timer                         `id:"valid_duration" unit:"ms" buckets:"1,10,100"`
+	Requests  requests                         `id:"valid_requests" kind:"counter"`
+	PerRoute  em.Family[string, em.I64Counter] `id:"valid_family" key:"route" maxkeys:"10"`
+	Gauge     em.I64ObservableGauge            `id:"valid_gauge" callback:"Observe"`
+	Nested    nested                           `attrs:"sub,nested"`
+	Other     nested                           `attrs:"sub,other"`
+	Workers   [4]nested                        `index:"worker"`
+	Shards    []*nested                        `len:"2" attrs:"sub,shard"`
+	Names     []string
+	Name      string
+}
+
+func (v *valid) Observe(context.Context, em.I64Observer) error { return nil }
+
+type requests em.I64Counter
+
+type nested struct {
+	Counter em.F64Counter `id:"nested_counter"`
+}
+
+type invalid struct {
+	Counter   em.I64Counter                         // want `missing id tag for field Counter`
+	Histogram em.F64Histogram                       `id:"h" buckets:"1,a"`                         // want `invalid buckets tag on field Histogram`
+	Gauge     em.F64Gauge                           `id:"g" buckets:"1,2"`                         // want `buckets tag on non-histogram field Gauge`
+	Timer     em.Timer                              `id:"t" unit:"us"`                             // want `unsupported unit "us" on timer field Timer`
+	Requests  requests                              `id:"r"`                                       // want `ambiguous kind for field Requests`
+	Family    em.Family[string, em.I64Counter]      `id:"f" maxkeys:"a"`                           // want `missing key tag for field Family` `invalid maxkeys tag on field Family`
+	ObsFamily em.Family[int, em.I64ObservableGauge] `id:"of" key:"k" callback:"Observe"`           // want `observable instruments are not supported by families`
+	Kind      em.I64Counter                         `id:"k" kind:"gauge"`                          // want `invalid kind tag on field Kind`
+	Observed  em.F64ObservableCounter               `id:"o"`                                       // want `missing callback tag for field Observed`
+	Exp       em.F64Histogram                       `id:"e" aggregation:"log"`                     // want `invalid aggregation tags on field Exp`
+	ExpCount  em.I64Counter                         `id:"c" aggregation:"exponential"`             // want `aggregation tags on non-histogram field ExpCount`
+	ExpBounds em.I64Histogram                       `id:"b" aggregation:"exponential" buckets:"1"` // want `buckets tag on exponential histogram field ExpBounds`
+	Nested    nested                                `attrs:"odd,attrs,count"`                      // want `invalid attrs tag on field Nested`
+	BadID     em.I64Counter                         `id:"requests count"`                          // want `invalid id tag on field BadID`
+	hidden    em.I64Counter                         `id:"hidden"`                                  // want `instrument field hidden is unexported`
+	Workers   []nested                              // want `slice field Workers of instrument structs is missing the len tag`
+	Shards    []nested                              `len:"-1"` // want `invalid len tag on field Shards`
+	Inline    struct {
+		Counter em.I64Counter // want `missing id tag for field Counter`
+	}
+}
+
+type duplicated struct { // want `duplicate instrument in duplicated: id dup_counter is used by fields Counter and Family` `duplicate instrument in duplicated: id dup_counter is used by fields Counter and Inner.Counter` `duplicate instrument in duplicated: id nested_counter is used by fields A.Counter and B.Counter` `duplicate instrument in duplicated: id c_nested_counter is used by fields C.Counter and D.Counter`
+	Counter em.I64Counter `id:"dup_counter"`
+	Inner   struct {
+		Counter em.I64Counter `id:"dup_counter"`
+	}
+	A nested
+	B *nested
+	// Prefixes tell instruments of the same struct type apart.
+	C nested  `prefix:"c_"`
+	D *nested `prefix:"c_"`
+	E nested  `prefix:"e_"`
+
+	Family em.Family[string, em.I64Counter] `id:"dup_counter" key:"k"`
+}
+
+// Structs without em tags hold instruments initialized elsewhere.
+type handler struct {
+	requests em.I64Counter
+	Latency  em.F64Histogram
+	Inner    struct {
+		Counter em.I64Counter
+	}
+}
+
+// Structs initialized through em are checked even without em tags, along
+// with those they nest.
+type untagged struct {
+	Counter em.I64Counter // want `missing id tag for field Counter`
+	Nested  untaggedNested
+}
+
+type untaggedNested struct {
+	Gauge em.F64Gauge // want `missing id tag for field Gauge`
+}
+
+var _, _ = em.Init[untagged]()
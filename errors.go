@@ -0,0 +1,115 @@
+package em
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// InitError reports every misconfigured field found while initializing an
+// instruments struct, so that all of them can be fixed at once.
+type InitError struct {
+	Fields []*FieldError
+}
+
+func (e *InitError) Error() string {
+	if len(e.Fields) == 1 {
+		return "error initializing instruments: " + e.Fields[0].Error()
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d errors initializing instruments:", len(e.Fields))
+	for _, f := range e.Fields {
+		sb.WriteString("\n\t")
+		sb.WriteString(f.Error())
+	}
+	return sb.String()
+}
+
+// Unwrap returns the field errors, for errors.Is and errors.As to inspect.
+func (e *InitError) Unwrap() []error {
+	errs := make([]error, len(e.Fields))
+	for i, f := range e.Fields {
+		errs[i] = f
+	}
+	return errs
+}
+
+// add records err for the field at path, splitting errors joined through
+// errors.Join into one entry each.
+func (e *InitError) add(path string, err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			e.add(path, err)
+		}
+		return
+	}
+
+	f := &FieldError{Path: path, Err: err}
+	var te *tagError
+	if errors.As(err, &te) {
+		f.Tag, f.Value, f.Err = te.tag, te.value, te.err
+	}
+	e.Fields = append(e.Fields, f)
+}
+
+// err returns e if any field error was recorded, or nil.
+func (e *InitError) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// FieldError describes a misconfigured field of an instruments struct.
+type FieldError struct {
+	// Path is the dotted path of the field from the initialized struct, such
+	// as Nested.MoreNest.Counter or Workers[1].Processed.
+	Path string
+	// Tag names the offending tag, if any.
+	Tag string
+	// Value is the raw value of the offending tag.
+	Value string
+	// Err is the cause of the error.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s: %s", e.Path, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// tagError ties an error to the tag of a field it originates from.
+type tagError struct {
+	tag   string
+	value string
+	err   error
+}
+
+func (e *tagError) Error() string {
+	return e.err.Error()
+}
+
+func (e *tagError) Unwrap() error {
+	return e.err
+}
+
+// errTag ties err, if any, to the named tag of f.
+func errTag(f reflect.StructField, tag string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &tagError{tag: tag, value: f.Tag.Get(tag), err: err}
+}
+
+// fieldPath returns the path of the named field within the struct at path.
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
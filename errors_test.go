@@ -0,0 +1,79 @@
+package em
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type misconfigured struct {
+	Counter   I64Counter
+	Histogram F64Histogram `id:"h" buckets:"1,a" aggregation:"log"`
+	Nested    struct {
+		Gauge    I64Gauge `id:"g"`
+		MoreNest struct {
+			Counter F64Counter
+		} `attrs:"odd"`
+	}
+	Workers [3]struct {
+		Observed I64ObservableGauge `id:"o" callback:"Missing"`
+	}
+	Timer  Timer                      `id:"t" unit:"us"`
+	Family Family[string, I64Counter] `id:"f" maxkeys:"-1"`
+	Valid  I64Counter                 `id:"valid"`
+}
+
+func TestInitError(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Does report every misconfigured field", func(t *testing.T) {
+		_, err := InitIn[misconfigured](New())
+
+		var initErr *InitError
+		require.ErrorAs(t, err, &initErr)
+
+		type entry struct{ path, tag, value string }
+		var entries []entry
+		for _, f := range initErr.Fields {
+			entries = append(entries, entry{f.Path, f.Tag, f.Value})
+		}
+		require.Equal(t, []entry{
+			{"Counter", "id", ""},
+			{"Histogram", "buckets", "1,a"},
+			{"Histogram", "aggregation", "log"},
+			{"Nested.MoreNest", "attrs", "odd"},
+			{"Nested.MoreNest.Counter", "id", ""},
+			{"Workers[0].Observed", "callback", "Missing"},
+			{"Timer", "unit", "us"},
+			{"Family", "key", ""},
+			{"Family", "maxkeys", "-1"},
+		}, entries)
+
+		require.ErrorContains(t, err, "9 errors initializing instruments:")
+		require.ErrorContains(t, err, "field Nested.MoreNest.Counter: missing id tag for field Counter")
+	})
+
+	t.Run("Does expose field errors to errors.As", func(t *testing.T) {
+		type instruments struct {
+			Nested struct {
+				Counter I64Counter `id:"c" kind:"gauge"`
+			}
+		}
+		_, err := InitIn[instruments](New())
+		require.EqualError(t, err, `error initializing instruments: field Nested.Counter: kind "gauge" does not apply to I64Counter for field Counter`)
+
+		var fieldErr *FieldError
+		require.ErrorAs(t, err, &fieldErr)
+		require.Equal(t, "Nested.Counter", fieldErr.Path)
+		require.Equal(t, "kind", fieldErr.Tag)
+		require.Equal(t, "gauge", fieldErr.Value)
+	})
+
+	t.Run("Does expose causes to errors.Is", func(t *testing.T) {
+		type instruments struct {
+			Counter I64Counter `id:"1st_counter"`
+		}
+		_, err := InitIn[instruments](New())
+		require.ErrorIs(t, err, ErrInvalidID)
+	})
+}
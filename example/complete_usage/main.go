@@ -54,7 +54,8 @@ func main() {
 
 	// You can initialize the same sampler more than once, but note that
 	// if they share the same identifiers, your metrics may be overridden.
-	// To avoid conflicts, add unique attributes to each sampler's measurements.
+	// To avoid conflicts, add unique attributes to each sampler's measurements,
+	// or nest the sampler under fields with distinct 'prefix' tags.
 	s2, err := em.Init[samplers](attribute.String("layer", "2"))
 	if err != nil {
 		panic(err)
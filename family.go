@@ -0,0 +1,172 @@
+package em
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// overflowAttr identifies the measurements of keys beyond the cardinality cap
+// of a family, as the OTEL SDK does for its own limits.
+var overflowAttr = attribute.Bool("otel.metric.overflow", true)
+
+// Family lazily creates and caches an instrument of type I per key, bound to
+// an attribute named by the 'key' tag holding the key. Keys beyond the limit
+// set by the 'maxkeys' tag share an instrument with the otel.metric.overflow
+// attribute instead. Families of observable instruments are not supported.
+//
+//	type server struct {
+//		PerRoute em.Family[string, em.I64Counter] `id:"http_requests" key:"route" maxkeys:"100"`
+//	}
+type Family[K comparable, I any] struct {
+	f *family[K, I]
+}
+
+type family[K comparable, I any] struct {
+	key      string
+	maxKeys  int
+	with     func(attrs ...attribute.KeyValue) any
+	overflow I
+
+	mu      sync.RWMutex
+	members map[K]I
+}
+
+// familyField is implemented by Family pointers, for Init to recognize Family
+// fields whatever their type arguments.
+type familyField interface {
+	instrumentType() reflect.Type
+	initFamily(base any, key string, maxKeys int) error
+}
+
+// NewFamily creates a family deriving its instruments from base. A maxKeys of
+// zero leaves the number of keys unbounded.
+func NewFamily[K comparable, I any](base I, key string, maxKeys int) (Family[K, I], error) {
+	f := Family[K, I]{}
+	err := f.initFamily(base, key, maxKeys)
+	return f, err
+}
+
+// Get returns the instrument of key k.
+func (f Family[K, I]) Get(k K) I {
+	f.f.mu.RLock()
+	inst, ok := f.f.members[k]
+	f.f.mu.RUnlock()
+	if ok {
+		return inst
+	}
+
+	f.f.mu.Lock()
+	defer f.f.mu.Unlock()
+	if inst, ok = f.f.members[k]; ok {
+		return inst
+	}
+	if f.f.maxKeys > 0 && len(f.f.members) >= f.f.maxKeys {
+		return f.f.overflow
+	}
+
+	inst = f.f.with(keyAttr(f.f.key, k)).(I)
+	f.f.members[k] = inst
+	return inst
+}
+
+func (f *Family[K, I]) instrumentType() reflect.Type {
+	return reflect.TypeOf((*I)(nil)).Elem()
+}
+
+func (f *Family[K, I]) initFamily(base any, key string, maxKeys int) error {
+	if key == "" {
+		return fmt.Errorf("missing key for family")
+	}
+	if maxKeys < 0 {
+		return fmt.Errorf("maxkeys must not be negative, got %d", maxKeys)
+	}
+
+	with, ok := withAttrs(base)
+	if !ok {
+		return fmt.Errorf("unsupported instrument type %T for family", base)
+	}
+
+	overflow, ok := with(overflowAttr).(I)
+	if !ok {
+		return fmt.Errorf("instrument type %T does not match family type %s", base, f.instrumentType())
+	}
+
+	f.f = &family[K, I]{
+		key:      key,
+		maxKeys:  maxKeys,
+		with:     with,
+		overflow: overflow,
+		members:  map[K]I{},
+	}
+	return nil
+}
+
+// withAttrs returns the With method of the instruments supporting it.
+func withAttrs(base any) (func(attrs ...attribute.KeyValue) any, bool) {
+	switch b := base.(type) {
+	case add[int64]:
+		return func(attrs ...attribute.KeyValue) any { return b.With(attrs...) }, true
+	case add[float64]:
+		return func(attrs ...attribute.KeyValue) any { return b.With(attrs...) }, true
+	case record[int64]:
+		return func(attrs ...attribute.KeyValue) any { return b.With(attrs...) }, true
+	case record[float64]:
+		return func(attrs ...attribute.KeyValue) any { return b.With(attrs...) }, true
+	case timing:
+		return func(attrs ...attribute.KeyValue) any { return b.With(attrs...) }, true
+	}
+	return nil, false
+}
+
+// keyAttr returns the attribute holding the key of a family member. Unsigned
+// keys beyond math.MaxInt64 are written in decimal as strings, as attributes
+// hold signed integers only.
+func keyAttr(key string, k any) attribute.KeyValue {
+	switch v := k.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int8:
+		return attribute.Int64(key, int64(v))
+	case int16:
+		return attribute.Int64(key, int64(v))
+	case int32:
+		return attribute.Int64(key, int64(v))
+	case int64:
+		return attribute.Int64(key, v)
+	case uint8:
+		return attribute.Int64(key, int64(v))
+	case uint16:
+		return attribute.Int64(key, int64(v))
+	case uint32:
+		return attribute.Int64(key, int64(v))
+	case uint:
+		return uintAttr(key, uint64(v))
+	case uint64:
+		return uintAttr(key, v)
+	case uintptr:
+		return uintAttr(key, uint64(v))
+	case float32:
+		return attribute.Float64(key, float64(v))
+	case float64:
+		return attribute.Float64(key, v)
+	case fmt.Stringer:
+		return attribute.String(key, v.String())
+	}
+	return attribute.String(key, fmt.Sprint(k))
+}
+
+func uintAttr(key string, v uint64) attribute.KeyValue {
+	if v > math.MaxInt64 {
+		return attribute.String(key, strconv.FormatUint(v, 10))
+	}
+	return attribute.Int64(key, int64(v))
+}
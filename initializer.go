@@ -1,18 +1,36 @@
 package em
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
 	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/ofeefo/em/internal/instrument"
 )
 
 const (
-	idTag      = "id"
-	bucketsTag = "buckets"
-	attrsTag   = "attrs"
+	idTag       = "id"
+	bucketsTag  = "buckets"
+	attrsTag    = "attrs"
+	callbackTag = "callback"
+	kindTag     = "kind"
+	keyTag      = "key"
+	maxKeysTag  = "maxkeys"
+	lenTag      = "len"
+	indexTag    = "index"
+	prefixTag   = "prefix"
+	descTag     = "desc"
+	unitTag     = "unit"
+
+	aggregationTag = "aggregation"
+	maxSizeTag     = "maxsize"
+	maxScaleTag    = "maxscale"
 )
 
 const (
@@ -23,6 +41,12 @@ const (
 	upDownCounter = "UpDownCounter"
 	gauge         = "Gauge"
 	histogram     = "Histogram"
+
+	observableCounter       = "ObservableCounter"
+	observableUpDownCounter = "ObservableUpDownCounter"
+	observableGauge         = "ObservableGauge"
+
+	timer = "Timer"
 )
 
 var (
@@ -30,42 +54,194 @@ var (
 	f64c      = reflect.TypeOf((*add[float64])(nil)).Elem()
 	i64r      = reflect.TypeOf((*record[int64])(nil)).Elem()
 	f64r      = reflect.TypeOf((*record[float64])(nil)).Elem()
-	supported = []reflect.Type{i64c, i64r, f64c, f64r}
+	obs       = reflect.TypeOf((*observable)(nil)).Elem()
+	tmr       = reflect.TypeOf((*timing)(nil)).Elem()
+	supported = []reflect.Type{i64c, i64r, f64c, f64r, obs, tmr}
+
+	i64Callback = reflect.TypeOf((func(context.Context, I64Observer) error)(nil))
 )
 
+// instrumentTypes maps em instrument types to their names.
+var instrumentTypes = map[reflect.Type]string{
+	reflect.TypeOf((*I64Counter)(nil)).Elem():                 "I64Counter",
+	reflect.TypeOf((*I64UpDownCounter)(nil)).Elem():           "I64UpDownCounter",
+	reflect.TypeOf((*I64Gauge)(nil)).Elem():                   "I64Gauge",
+	reflect.TypeOf((*I64Histogram)(nil)).Elem():               "I64Histogram",
+	reflect.TypeOf((*F64Counter)(nil)).Elem():                 "F64Counter",
+	reflect.TypeOf((*F64UpDownCounter)(nil)).Elem():           "F64UpDownCounter",
+	reflect.TypeOf((*F64Gauge)(nil)).Elem():                   "F64Gauge",
+	reflect.TypeOf((*F64Histogram)(nil)).Elem():               "F64Histogram",
+	reflect.TypeOf((*I64ObservableCounter)(nil)).Elem():       "I64ObservableCounter",
+	reflect.TypeOf((*I64ObservableUpDownCounter)(nil)).Elem(): "I64ObservableUpDownCounter",
+	reflect.TypeOf((*I64ObservableGauge)(nil)).Elem():         "I64ObservableGauge",
+	reflect.TypeOf((*F64ObservableCounter)(nil)).Elem():       "F64ObservableCounter",
+	reflect.TypeOf((*F64ObservableUpDownCounter)(nil)).Elem(): "F64ObservableUpDownCounter",
+	reflect.TypeOf((*F64ObservableGauge)(nil)).Elem():         "F64ObservableGauge",
+	reflect.TypeOf((*Timer)(nil)).Elem():                      "Timer",
+}
+
 func typeAndKindFor(typeName string) (t, kind string) {
+	if typeName == timer {
+		return f64Type, timer
+	}
 	t = string(typeName[0])
 	kind = typeName[3:]
 	return
 }
 
+// resolveType returns the name of the em instrument type of field. Types
+// defined on top of em ones, such as `type RequestCounter em.I64Counter`, only
+// share their method set, so they are told apart through the 'kind' tag and,
+// for observables, the signature of their callback.
+func resolveType(owner reflect.Value, field reflect.StructField) (string, error) {
+	if field.Type.Kind() != reflect.Interface {
+		return "", fmt.Errorf("unsupported instrument type %s for field %s", field.Type, field.Name)
+	}
+
+	var candidates []string
+	if name, ok := instrumentTypes[field.Type]; ok {
+		candidates = []string{name}
+	} else {
+		for typ, name := range instrumentTypes {
+			if field.Type.Implements(typ) && typ.Implements(field.Type) {
+				candidates = append(candidates, name)
+			}
+		}
+		sort.Strings(candidates)
+	}
+	// Types embedding em ones along with other methods implement them, but
+	// can't be initialized as any.
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("unsupported instrument type %s for field %s", field.Type, field.Name)
+	}
+
+	res, err := instrument.Resolve(candidates, field.Tag.Get(kindTag))
+	if err != nil {
+		return "", fmt.Errorf("%s for field %s", err, field.Name)
+	}
+
+	if len(res) > 1 && instrument.Types[res[0]].Observable {
+		prefix := f64Type
+		if cb, err := getCallback(owner, field); err == nil && cb.Type() == i64Callback {
+			prefix = i64Type
+		}
+		var filtered []string
+		for _, name := range res {
+			if strings.HasPrefix(name, prefix) {
+				filtered = append(filtered, name)
+			}
+		}
+		res = filtered
+	}
+
+	if len(res) > 1 {
+		return "", fmt.Errorf("ambiguous kind for field %s of type %s, which could be any of %s: set the kind tag", field.Name, field.Type, strings.Join(res, ", "))
+	}
+	return res[0], nil
+}
+
 func MustInit[T any](attrs ...attribute.KeyValue) *T {
-	res, err := Init[T](attrs...)
+	return MustInitIn[T](defaultRegistry, attrs...)
+}
+
+func Init[T any](attrs ...attribute.KeyValue) (*T, error) {
+	return InitIn[T](defaultRegistry, attrs...)
+}
+
+// MustInitIn is like InitIn, but panics if initialization fails.
+func MustInitIn[T any](r *Registry, attrs ...attribute.KeyValue) *T {
+	res, err := InitIn[T](r, attrs...)
 	if err != nil {
 		panic(err)
 	}
 	return res
 }
 
-func Init[T any](attrs ...attribute.KeyValue) (*T, error) {
+// InitIn initializes the instruments of T using the meter owned by r.
+func InitIn[T any](r *Registry, attrs ...attribute.KeyValue) (*T, error) {
 	s := new(T)
-	if err := initRef(s, attrs...); err != nil {
+	if kind := reflect.TypeOf(s).Elem().Kind(); kind != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct type, got %s", kind.String())
+	}
+
+	st := &initState{}
+	initRef(r, s, r.Namespace(), "", st, attrs...)
+	if err := st.errs.err(); err != nil {
+		// The callbacks of the valid observables would otherwise keep being
+		// called for a struct the caller can't close.
+		_ = closeRef(reflect.ValueOf(s).Elem())
 		return nil, err
 	}
 	return s, nil
 }
 
-func initRef(base any, attrs ...attribute.KeyValue) error {
-	sType := reflect.TypeOf(base)
-	sVal := reflect.ValueOf(base)
-	if sType.Kind() == reflect.Pointer {
-		sType = sType.Elem()
-		sVal = sVal.Elem()
+// Close unregisters the callbacks of every observable instrument found in the
+// provided instruments struct, including nested, embedded and element ones.
+func Close(s any) error {
+	sVal := reflect.Indirect(reflect.ValueOf(s))
+	if sVal.Kind() != reflect.Struct {
+		return fmt.Errorf("expected a struct type, got %s", sVal.Kind().String())
 	}
+	return closeRef(sVal)
+}
+
+func closeRef(sVal reflect.Value) error {
+	var errs []error
+	for i := 0; i < sVal.NumField(); i++ {
+		if !sVal.Type().Field(i).IsExported() {
+			continue
+		}
 
-	if sType.Kind() != reflect.Struct {
-		return fmt.Errorf("expected a struct type, got %s", sType.Kind().String())
+		fVal := reflect.Indirect(sVal.Field(i))
+		switch {
+		case fVal.Kind() == reflect.Struct:
+			errs = append(errs, closeRef(fVal))
+		case fVal.Kind() == reflect.Array || fVal.Kind() == reflect.Slice:
+			for j := 0; j < fVal.Len(); j++ {
+				if elem := reflect.Indirect(fVal.Index(j)); elem.Kind() == reflect.Struct {
+					errs = append(errs, closeRef(elem))
+				}
+			}
+		case fVal.Kind() == reflect.Interface && !fVal.IsNil():
+			if o, ok := fVal.Interface().(observable); ok {
+				errs = append(errs, o.Unregister())
+			}
+		}
 	}
+	return errors.Join(errs...)
+}
+
+// initState holds the outcome of the initialization of an instruments struct.
+type initState struct {
+	errs InitError
+	// describe, if set, is called with every instrument found, which is then
+	// left uninitialized.
+	describe func(d described)
+}
+
+// described is an instrument found by initRef.
+type described struct {
+	// path is the dotted path of its field.
+	path string
+	// typeName is the name of its em type, such as I64Counter or Timer.
+	typeName string
+	spec     Spec
+	attrs    []attribute.KeyValue
+	// key is the 'key' tag of family fields.
+	key string
+	// indexes are the attributes holding the indexes of the elements of
+	// arrays and slices enclosing its field, outermost first, which are left
+	// out of attrs.
+	indexes []string
+}
+
+// initRef initializes the instruments of the struct base points to, whose ids
+// are prepended with prefix, recording the errors of its fields into st under
+// path.
+func initRef(r *Registry, base any, prefix, path string, st *initState, attrs ...attribute.KeyValue) {
+	owner := reflect.ValueOf(base)
+	sVal := owner.Elem()
+	sType := sVal.Type()
 
 	for i := 0; i < sType.NumField(); i++ {
 		field := sType.Field(i)
@@ -74,82 +250,130 @@ func initRef(base any, attrs ...attribute.KeyValue) error {
 		}
 
 		fVal := sVal.Field(i)
-		fTName := field.Type.Name()
-		if fTName == "" {
-			fTName = sType.Name()
+		fPath := fieldPath(path, field.Name)
+
+		if ff, ok := fVal.Addr().Interface().(familyField); ok {
+			if err := initializeFamily(r, st, owner, field, ff, prefix, fPath, attrs...); err != nil {
+				st.errs.add(fPath, err)
+			}
+			continue
+		}
+
+		if isElements(field) {
+			initializeElements(r, st, field, fVal, prefix, fPath, attrs...)
+			continue
 		}
 
 		// nolint: nestif
 		if fVal.Kind() == reflect.Struct || fVal.Kind() == reflect.Ptr {
-			innerAttrs, err := extractTag(field, getAttrs)
+			// Nested structs are still initialized on invalid attributes, to
+			// report the errors of their fields as well.
+			innerAttrs, err := extractTag(field, attrsTag, getAttrs)
 			if err != nil {
-				return err
+				st.errs.add(fPath, err)
 			}
 
-			n := reflect.New(field.Type)
-			isPtr := reflect.Indirect(n).Kind() == reflect.Ptr
+			// Nested structs are initialized in place, so callback methods
+			// bound during initialization refer to the struct in use.
+			n := fVal.Addr()
+			isPtr := fVal.Kind() == reflect.Ptr
 			if isPtr {
 				n = reflect.New(field.Type.Elem())
 			}
 
-			eAttrs := append(attrs, innerAttrs...)
-			if err = initRef(n.Interface(), eAttrs...); err != nil {
-				return fmt.Errorf("field initialization failed: %s", err)
-			}
+			eAttrs := append(attrs[:len(attrs):len(attrs)], innerAttrs...)
+			initRef(r, n.Interface(), prefix+field.Tag.Get(prefixTag), fPath, st, eAttrs...)
 
 			if isPtr {
 				fVal.Set(n)
-			} else {
-				fVal.Set(n.Elem())
 			}
 			continue
 		}
 
 		if implementsOneOf(field.Type, supported...) {
-			t, kind := typeAndKindFor(fTName)
-			val, err := initializeByKind(t, kind, field, attrs...)
+			name, err := resolveType(owner, field)
 			if err != nil {
-				return fmt.Errorf("error initializing field: %s", err)
+				st.errs.add(fPath, errTag(field, kindTag, err))
+				continue
+			}
+
+			val, err := initializeByKind(r, st, name, owner, field, prefix, fPath, attrs...)
+			if err != nil {
+				st.errs.add(fPath, err)
+				continue
+			}
+			if val != nil {
+				fVal.Set(reflect.ValueOf(val))
 			}
-			fVal.Set(reflect.ValueOf(val))
 		}
 	}
-	return nil
 }
 
-func initializeByKind(t, kind string, field reflect.StructField, attrs ...attribute.KeyValue) (any, error) {
+// initializeByKind creates the instrument of the named type for a field, or
+// describes it to st. Errors of its tags are joined, to be reported together.
+func initializeByKind(r *Registry, st *initState, name string, owner reflect.Value, field reflect.StructField, prefix, path string, attrs ...attribute.KeyValue) (any, error) {
 	var (
-		id  string
-		res any
-		err error
+		res  any
+		err  error
+		errs []error
 	)
 
-	id, err = extractTag(field, getID)
-	if err != nil {
+	t, kind := typeAndKindFor(name)
+	id, err := extractTag(field, idTag, getID)
+	errs = append(errs, err)
+	if id != "" {
+		errs = append(errs, errTag(field, idTag, ValidateID(prefix+id)))
+	}
+	spec := Spec{ID: prefix + id, Description: field.Tag.Get(descTag), Unit: field.Tag.Get(unitTag)}
+
+	if kind == histogram || kind == timer {
+		spec.Buckets, err = extractTag(field, bucketsTag, getBounds)
+		errs = append(errs, err)
+		spec.Exponential, err = extractTag(field, aggregationTag, getAggregation)
+		errs = append(errs, err)
+		if len(spec.Buckets) > 0 && spec.Exponential != nil {
+			errs = append(errs, errTag(field, bucketsTag, fmt.Errorf("buckets cannot be used with the exponential aggregation for field %s", field.Name)))
+		}
+	}
+	if kind == timer {
+		_, err = timerUnit(spec.Unit)
+		errs = append(errs, errTag(field, unitTag, err))
+	}
+	if err = errors.Join(errs...); err != nil {
 		return nil, err
 	}
 
-	switch kind {
-	case counter, upDownCounter:
-		if t == i64Type {
-			res, err = prov.i64c(kind, id, attrs...)
-		} else {
-			res, err = prov.f64c(kind, id, attrs...)
-		}
-	case gauge, histogram:
-		var bounds []float64
-		if kind == histogram {
-			bounds, err = extractTag(field, getBounds)
-			if err != nil {
-				return nil, err
+	if st.describe != nil {
+		if instrument.Types[name].Observable {
+			if _, err = getCallback(owner, field); err != nil {
+				return nil, errTag(field, callbackTag, err)
 			}
 		}
+		if kind == timer {
+			spec, _, _ = timerSpec(spec)
+		}
+		st.describe(described{path: path, typeName: name, spec: spec, attrs: attrs})
+		return nil, nil
+	}
 
+	switch kind {
+	case counter, upDownCounter:
 		if t == i64Type {
-			res, err = prov.i64r(kind, id, bounds, attrs...)
+			res, err = r.i64c(kind, spec, attrs...)
 		} else {
-			res, err = prov.f64r(kind, id, bounds, attrs...)
+			res, err = r.f64c(kind, spec, attrs...)
+		}
+	case gauge, histogram, timer:
+		switch {
+		case kind == timer:
+			res, err = r.timer(spec, attrs...)
+		case t == i64Type:
+			res, err = r.i64r(kind, spec, attrs...)
+		default:
+			res, err = r.f64r(kind, spec, attrs...)
 		}
+	case observableCounter, observableUpDownCounter, observableGauge:
+		res, err = initializeObservable(r, t, kind, spec, owner, field, attrs...)
 	}
 
 	if err != nil {
@@ -163,6 +387,154 @@ func initializeByKind(t, kind string, field reflect.StructField, attrs ...attrib
 	return res, nil
 }
 
+// isElements tells whether field is an array of nested structs, or a slice of
+// them with a 'len' tag.
+func isElements(field reflect.StructField) bool {
+	switch field.Type.Kind() {
+	case reflect.Array:
+	case reflect.Slice:
+		if field.Tag.Get(lenTag) == "" {
+			return false
+		}
+	default:
+		return false
+	}
+
+	elem := field.Type.Elem()
+	if elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	return elem.Kind() == reflect.Struct
+}
+
+// initializeElements initializes every element of an array or slice field as
+// a nested struct, adding an attribute holding its index, named by the
+// 'index' tag. As elements share their tags, only the errors of the first
+// failing one are reported.
+func initializeElements(r *Registry, st *initState, field reflect.StructField, fVal reflect.Value, prefix, path string, attrs ...attribute.KeyValue) {
+	innerAttrs, err := extractTag(field, attrsTag, getAttrs)
+	if err != nil {
+		st.errs.add(path, err)
+	}
+	key := field.Tag.Get(indexTag)
+	if key == "" {
+		key = "index"
+	}
+
+	if fVal.Kind() == reflect.Slice {
+		n, err := extractTag(field, lenTag, getLen)
+		if err != nil {
+			st.errs.add(path, err)
+			return
+		}
+		fVal.Set(reflect.MakeSlice(field.Type, n, n))
+	}
+
+	// Instruments are described along with the attribute holding the index of
+	// their element, rather than its values.
+	if describe := st.describe; describe != nil {
+		st.describe = func(d described) {
+			d.indexes = append([]string{key}, d.indexes...)
+			dAttrs := make([]attribute.KeyValue, 0, len(d.attrs))
+			for _, a := range d.attrs {
+				if string(a.Key) != key {
+					dAttrs = append(dAttrs, a)
+				}
+			}
+			d.attrs = dAttrs
+			describe(d)
+		}
+		defer func() { st.describe = describe }()
+	}
+
+	eAttrs := append(attrs[:len(attrs):len(attrs)], innerAttrs...)
+	for i := 0; i < fVal.Len(); i++ {
+		elem := fVal.Index(i)
+		n := elem.Addr()
+		isPtr := elem.Kind() == reflect.Ptr
+		if isPtr {
+			n = reflect.New(elem.Type().Elem())
+		}
+
+		reported := len(st.errs.Fields)
+		initRef(r, n.Interface(), prefix+field.Tag.Get(prefixTag), fmt.Sprintf("%s[%d]", path, i), st, append(eAttrs[:len(eAttrs):len(eAttrs)], attribute.Int(key, i))...)
+		// Failing elements are set too, for their observables to be closed.
+		if isPtr {
+			elem.Set(n)
+		}
+		if len(st.errs.Fields) > reported {
+			return
+		}
+	}
+}
+
+// initializeFamily initializes the instrument a Family derives its members
+// from, as a field of the family's instrument type would be.
+func initializeFamily(r *Registry, st *initState, owner reflect.Value, field reflect.StructField, ff familyField, prefix, path string, attrs ...attribute.KeyValue) error {
+	var errs []error
+	key := field.Tag.Get(keyTag)
+	if key == "" {
+		errs = append(errs, errTag(field, keyTag, fmt.Errorf("missing key tag for field %s", field.Name)))
+	}
+	maxKeys, err := extractTag(field, maxKeysTag, getMaxKeys)
+	errs = append(errs, err)
+
+	inst := field
+	inst.Type = ff.instrumentType()
+	if !implementsOneOf(inst.Type, supported...) {
+		errs = append(errs, fmt.Errorf("unsupported instrument type %s for family field %s", inst.Type, field.Name))
+		return errors.Join(errs...)
+	}
+	name, err := resolveType(owner, inst)
+	if err != nil {
+		errs = append(errs, errTag(field, kindTag, err))
+		return errors.Join(errs...)
+	}
+	if instrument.Types[name].Observable {
+		errs = append(errs, fmt.Errorf("observable instruments are not supported by families, for field %s", field.Name))
+		return errors.Join(errs...)
+	}
+
+	// Instruments are described along with the key of the family.
+	fst := st
+	if st.describe != nil {
+		fst = &initState{describe: func(d described) {
+			d.key = key
+			st.describe(d)
+		}}
+	}
+	base, err := initializeByKind(r, fst, name, owner, inst, prefix, path, attrs...)
+	errs = append(errs, err)
+	if err = errors.Join(errs...); err != nil || base == nil {
+		return err
+	}
+	if err = ff.initFamily(base, key, maxKeys); err != nil {
+		return fmt.Errorf("%s for field %s", err, field.Name)
+	}
+	return nil
+}
+
+func initializeObservable(r *Registry, t, kind string, spec Spec, owner reflect.Value, field reflect.StructField, attrs ...attribute.KeyValue) (observable, error) {
+	cb, err := getCallback(owner, field)
+	if err != nil {
+		return nil, errTag(field, callbackTag, err)
+	}
+
+	if t == i64Type {
+		fn, ok := cb.Interface().(func(context.Context, I64Observer) error)
+		if !ok {
+			return nil, errTag(field, callbackTag, fmt.Errorf("callback %s for field %s must be a func(context.Context, em.I64Observer) error", field.Tag.Get(callbackTag), field.Name))
+		}
+		return r.i64o(kind, spec, fn, attrs...)
+	}
+
+	fn, ok := cb.Interface().(func(context.Context, F64Observer) error)
+	if !ok {
+		return nil, errTag(field, callbackTag, fmt.Errorf("callback %s for field %s must be a func(context.Context, em.F64Observer) error", field.Tag.Get(callbackTag), field.Name))
+	}
+	return r.f64o(kind, spec, fn, attrs...)
+}
+
 func getID(f reflect.StructField) (string, error) {
 	id := f.Tag.Get(idTag)
 	if id == "" {
@@ -171,15 +543,116 @@ func getID(f reflect.StructField) (string, error) {
 	return id, nil
 }
 
+func getLen(f reflect.StructField) (int, error) {
+	raw := f.Tag.Get(lenTag)
+	n, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid len %q on field %s", raw, f.Name)
+	}
+	return n, nil
+}
+
+func getMaxKeys(f reflect.StructField) (int, error) {
+	raw := f.Tag.Get(maxKeysTag)
+	if raw == "" {
+		return 0, nil
+	}
+	maxKeys, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || maxKeys < 0 {
+		return 0, fmt.Errorf("invalid maxkeys %q on field %s", raw, f.Name)
+	}
+	return maxKeys, nil
+}
+
 func getBounds(f reflect.StructField) ([]float64, error) {
-	rawBounds := f.Tag.Get(bucketsTag)
+	return ParseBuckets(f.Tag.Get(bucketsTag))
+}
+
+// ParseBuckets parses the value of a 'buckets' tag, which is either a
+// comma-separated list of boundaries or one of the following generators:
+//   - exp(start,factor,count): count boundaries starting at start, each one
+//     factor times the previous one.
+//   - linear(start,width,count): count boundaries starting at start, each one
+//     width greater than the previous one.
+//
+// Boundaries must be strictly increasing.
+func ParseBuckets(rawBounds string) ([]float64, error) {
 	bounds := []float64{}
 	if rawBounds == "" {
 		return bounds, nil
 	}
 
+	var err error
+	trimmed := strings.TrimSpace(rawBounds)
+	switch {
+	case strings.HasPrefix(trimmed, "exp("):
+		bounds, err = generateBounds(trimmed, "exp", expBounds)
+	case strings.HasPrefix(trimmed, "linear("):
+		bounds, err = generateBounds(trimmed, "linear", linearBounds)
+	default:
+		bounds, err = parseBoundsList(rawBounds)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i < len(bounds); i++ {
+		if bounds[i] <= bounds[i-1] {
+			return nil, fmt.Errorf("buckets [%s] must be strictly increasing: %v follows %v", rawBounds, bounds[i], bounds[i-1])
+		}
+	}
+	return bounds, nil
+}
+
+func getAggregation(f reflect.StructField) (*Exponential, error) {
+	e, err := ParseAggregation(f.Tag.Get(aggregationTag), f.Tag.Get(maxSizeTag), f.Tag.Get(maxScaleTag))
+	if err != nil {
+		return nil, fmt.Errorf("%s on field %s", err, f.Name)
+	}
+	return e, nil
+}
+
+// ParseAggregation parses the 'aggregation', 'maxsize' and 'maxscale' tags of
+// a histogram. The aggregation is either "explicit" (the default), for which
+// nil is returned, or "exponential". Omitted sizes default to those of OTEL,
+// 160 buckets and a scale of 20.
+func ParseAggregation(aggregation, maxSize, maxScale string) (*Exponential, error) {
+	switch aggregation {
+	case "", "explicit":
+		if maxSize != "" || maxScale != "" {
+			return nil, fmt.Errorf("maxsize and maxscale require the exponential aggregation")
+		}
+		return nil, nil
+	case "exponential":
+	default:
+		return nil, fmt.Errorf("unsupported aggregation %q", aggregation)
+	}
+
+	e := &Exponential{MaxSize: 160, MaxScale: 20}
+	if maxSize != "" {
+		v, err := strconv.ParseInt(strings.TrimSpace(maxSize), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing maxsize %q: %s", maxSize, err)
+		}
+		e.MaxSize = int32(v)
+	}
+	if maxScale != "" {
+		v, err := strconv.ParseInt(strings.TrimSpace(maxScale), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing maxscale %q: %s", maxScale, err)
+		}
+		e.MaxScale = int32(v)
+	}
+
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func parseBoundsList(rawBounds string) ([]float64, error) {
 	sRawBounds := strings.Split(rawBounds, ",")
-	bounds = make([]float64, 0, len(sRawBounds))
+	bounds := make([]float64, 0, len(sRawBounds))
 	for _, b := range sRawBounds {
 		b = strings.TrimSpace(b)
 		bucket, err := strconv.ParseFloat(b, 64)
@@ -191,31 +664,217 @@ func getBounds(f reflect.StructField) ([]float64, error) {
 	return bounds, nil
 }
 
+func generateBounds(raw, name string, gen func(start, step float64, count int) ([]float64, error)) ([]float64, error) {
+	args, ok := strings.CutSuffix(strings.TrimPrefix(raw, name+"("), ")")
+	sArgs := strings.Split(args, ",")
+	if !ok || len(sArgs) != 3 {
+		return nil, fmt.Errorf("invalid buckets [%s]: expected %s(start,step,count)", raw, name)
+	}
+
+	start, err := strconv.ParseFloat(strings.TrimSpace(sArgs[0]), 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing buckets [%s]: %s", raw, err)
+	}
+	step, err := strconv.ParseFloat(strings.TrimSpace(sArgs[1]), 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing buckets [%s]: %s", raw, err)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(sArgs[2]))
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing buckets [%s]: %s", raw, err)
+	}
+	if count <= 0 {
+		return nil, fmt.Errorf("invalid buckets [%s]: count must be positive", raw)
+	}
+
+	bounds, err := gen(start, step, count)
+	if err != nil {
+		return nil, fmt.Errorf("invalid buckets [%s]: %s", raw, err)
+	}
+	return bounds, nil
+}
+
+func expBounds(start, factor float64, count int) ([]float64, error) {
+	if start <= 0 {
+		return nil, fmt.Errorf("start must be positive")
+	}
+	if factor <= 1 {
+		return nil, fmt.Errorf("factor must be greater than 1")
+	}
+
+	bounds := make([]float64, count)
+	for i := range bounds {
+		bounds[i] = start
+		start *= factor
+	}
+	return bounds, nil
+}
+
+func linearBounds(start, width float64, count int) ([]float64, error) {
+	if width <= 0 {
+		return nil, fmt.Errorf("width must be positive")
+	}
+
+	bounds := make([]float64, count)
+	for i := range bounds {
+		bounds[i] = start + float64(i)*width
+	}
+	return bounds, nil
+}
+
+func getCallback(owner reflect.Value, f reflect.StructField) (reflect.Value, error) {
+	name := f.Tag.Get(callbackTag)
+	if name == "" {
+		return reflect.Value{}, fmt.Errorf("missing callback tag for field %s", f.Name)
+	}
+
+	cb := owner.MethodByName(name)
+	if !cb.IsValid() {
+		return reflect.Value{}, fmt.Errorf("callback method %s not found for field %s", name, f.Name)
+	}
+	return cb, nil
+}
+
 func getAttrs(f reflect.StructField) ([]attribute.KeyValue, error) {
-	rawAttrs := f.Tag.Get(attrsTag)
+	attrs, err := ParseAttrs(f.Tag.Get(attrsTag))
+	if err != nil {
+		return nil, fmt.Errorf("%s on field %s", err, f.Name)
+	}
+	return attrs, nil
+}
+
+// ParseAttrs parses the value of an 'attrs' tag. Attributes are either
+// provided as a comma-separated list of string keys and values
+// ("key,value,key,value"), or as comma-separated key=value pairs whose values
+// may be prefixed by their type ("shard=int:3,primary=bool:true"). Supported
+// types are string, int, bool, float and their slices ([]string, []int,
+// []bool, []float), whose elements are separated by '|'. Untyped values are
+// strings. Lists are parsed as key=value pairs only when all of their elements
+// are, so that values of key,value lists may hold '=' ("query,a=b").
+func ParseAttrs(rawAttrs string) ([]attribute.KeyValue, error) {
 	attrs := []attribute.KeyValue{}
 	if rawAttrs == "" {
 		return attrs, nil
 	}
 
 	sAttrs := strings.Split(rawAttrs, ",")
+	if typedAttrs(sAttrs) {
+		return parseTypedAttrs(sAttrs)
+	}
+
 	if len(sAttrs)%2 != 0 {
-		return nil, fmt.Errorf("invalid number of attributes on field %s: %d", f.Name, len(sAttrs))
+		return nil, fmt.Errorf("invalid number of attributes: %d", len(sAttrs))
 	}
 
 	attrs = make([]attribute.KeyValue, 0, len(sAttrs)/2)
 	for i := 0; i < len(sAttrs)-1; i += 2 {
 		k := strings.TrimSpace(sAttrs[i])
 		v := strings.TrimSpace(sAttrs[i+1])
+		if strings.Contains(k, "=") {
+			return nil, fmt.Errorf("invalid attribute key %q: key=value pairs cannot be mixed with key,value lists", k)
+		}
 		attrs = append(attrs, attribute.String(k, v))
 	}
 	return attrs, nil
 }
 
-func extractTag[T any](f reflect.StructField, fn func(f reflect.StructField) (T, error)) (T, error) {
+// typedAttrs tells whether all the elements of an 'attrs' tag are key=value
+// pairs.
+func typedAttrs(sAttrs []string) bool {
+	for _, a := range sAttrs {
+		if !strings.Contains(a, "=") {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTypedAttrs(sAttrs []string) ([]attribute.KeyValue, error) {
+	attrs := make([]attribute.KeyValue, 0, len(sAttrs))
+	for _, a := range sAttrs {
+		k, v, ok := strings.Cut(a, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid attribute %q: expected key=value", strings.TrimSpace(a))
+		}
+
+		kv, err := parseTypedAttr(k, strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, kv)
+	}
+	return attrs, nil
+}
+
+func parseTypedAttr(k, v string) (attribute.KeyValue, error) {
+	typ, lit, ok := strings.Cut(v, ":")
+	if !ok {
+		return attribute.String(k, v), nil
+	}
+
+	var (
+		res attribute.KeyValue
+		err error
+	)
+	switch typ {
+	case "string":
+		res = attribute.String(k, lit)
+	case "int":
+		var i int64
+		i, err = strconv.ParseInt(lit, 10, 64)
+		res = attribute.Int64(k, i)
+	case "bool":
+		var b bool
+		b, err = strconv.ParseBool(lit)
+		res = attribute.Bool(k, b)
+	case "float":
+		var f float64
+		f, err = strconv.ParseFloat(lit, 64)
+		res = attribute.Float64(k, f)
+	case "[]string":
+		res = attribute.StringSlice(k, strings.Split(lit, "|"))
+	case "[]int":
+		var is []int64
+		is, err = parseSlice(lit, func(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) })
+		res = attribute.Int64Slice(k, is)
+	case "[]bool":
+		var bs []bool
+		bs, err = parseSlice(lit, strconv.ParseBool)
+		res = attribute.BoolSlice(k, bs)
+	case "[]float":
+		var fs []float64
+		fs, err = parseSlice(lit, func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+		res = attribute.Float64Slice(k, fs)
+	default:
+		// Values such as "http://host" are not typed.
+		return attribute.String(k, v), nil
+	}
+
+	if err != nil {
+		return attribute.KeyValue{}, fmt.Errorf("invalid %s value for attribute %s: %q", typ, k, lit)
+	}
+	return res, nil
+}
+
+func parseSlice[T any](lit string, parse func(string) (T, error)) ([]T, error) {
+	parts := strings.Split(lit, "|")
+	res := make([]T, 0, len(parts))
+	for _, p := range parts {
+		v, err := parse(strings.TrimSpace(p))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+// extractTag calls fn on f, tying its error to the named tag.
+func extractTag[T any](f reflect.StructField, tag string, fn func(f reflect.StructField) (T, error)) (T, error) {
 	res, err := fn(f)
 	if err != nil {
-		return *new(T), err
+		return *new(T), errTag(f, tag, err)
 	}
 	return res, nil
 }
@@ -1,11 +1,21 @@
 package em
 
 import (
+	"context"
+	"math"
 	"reflect"
+	"runtime"
 	"testing"
+	"time"
 
+	promclient "github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	m2 "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
 )
 
 func TestInitialize(t *testing.T) {
@@ -97,21 +107,51 @@ func TestGetBounds(t *testing.T) {
 		require.Error(t, err)
 	})
 
+	t.Run("Fails when bounds are not strictly increasing", func(t *testing.T) {
+		invalid := struct {
+			Bounds string `buckets:"1.0,0.5874697321, 5.343, 0.9"`
+		}{}
+
+		field := getField0(t, invalid)
+		_, err := getBounds(field)
+		require.ErrorContains(t, err, "strictly increasing")
+
+		_, err = ParseBuckets("1,2,2")
+		require.Error(t, err)
+	})
+
 	t.Run("Correctly retrieve all buckets", func(t *testing.T) {
 		expectedBounds := []float64{
-			1.0,
 			0.5874697321,
-			5.343,
 			0.9,
+			1.0,
+			5.343,
 		}
 		valid := struct {
-			Bounds string `buckets:"1.0,0.5874697321, 5.343, 0.9"`
+			Bounds string `buckets:"0.5874697321, 0.9, 1.0, 5.343"`
 		}{}
 
 		field := getField0(t, valid)
 		bounds, err := getBounds(field)
 		require.NoError(t, err)
-		require.ElementsMatch(t, expectedBounds, bounds)
+		require.Equal(t, expectedBounds, bounds)
+	})
+
+	t.Run("Correctly generates buckets", func(t *testing.T) {
+		bounds, err := ParseBuckets("exp(0.001, 2, 4)")
+		require.NoError(t, err)
+		require.Equal(t, []float64{0.001, 0.002, 0.004, 0.008}, bounds)
+
+		bounds, err = ParseBuckets("linear(0,50,4)")
+		require.NoError(t, err)
+		require.Equal(t, []float64{0, 50, 100, 150}, bounds)
+	})
+
+	t.Run("Fails with invalid generators", func(t *testing.T) {
+		for _, raw := range []string{"exp(0,2,3)", "exp(1,1,3)", "exp(1,2)", "exp(1,2,0)", "linear(0,0,3)", "linear(0,a,3)", "linear(0,1,3", "log(1,2,3)"} {
+			_, err := ParseBuckets(raw)
+			require.Errorf(t, err, "expected %q to fail", raw)
+		}
 	})
 }
 
@@ -153,6 +193,54 @@ func TestGetAttrs(t *testing.T) {
 		require.ElementsMatch(t, expectedAttrs, attrs)
 	})
 
+	t.Run("Correctly retrieve typed attributes", func(t *testing.T) {
+		expectedAttrs := []attribute.KeyValue{
+			attribute.Int64("shard", 3),
+			attribute.Bool("primary", true),
+			attribute.Float64("ratio", 0.5),
+			attribute.StringSlice("tags", []string{"a", "b"}),
+			attribute.Int64Slice("ports", []int64{80, 443}),
+			attribute.String("name", "plain"),
+			attribute.String("url", "http://host"),
+		}
+
+		valid := struct {
+			Embed struct{} `attrs:"shard=int:3, primary=bool:true, ratio=float:0.5, tags=[]string:a|b, ports=[]int:80|443, name=plain, url=http://host"`
+		}{}
+		field := getField0(t, valid)
+		attrs, err := getAttrs(field)
+		require.NoError(t, err)
+		require.Equal(t, expectedAttrs, attrs)
+	})
+
+	t.Run("Correctly retrieve attributes whose values hold '='", func(t *testing.T) {
+		expectedAttrs := []attribute.KeyValue{
+			attribute.String("query", "a=b"),
+			attribute.String("mode", "fast"),
+		}
+
+		valid := struct {
+			Embed struct{} `attrs:"query,a=b,mode,fast"`
+		}{}
+		field := getField0(t, valid)
+		attrs, err := getAttrs(field)
+		require.NoError(t, err)
+		require.Equal(t, expectedAttrs, attrs)
+	})
+
+	t.Run("Fails with invalid typed literals", func(t *testing.T) {
+		for _, raw := range []string{"shard=int:three", "primary=bool:maybe", "ratio=float:half", "ports=[]int:80|x", "shard=int:3,plain"} {
+			_, err := ParseAttrs(raw)
+			require.Errorf(t, err, "expected %q to fail", raw)
+		}
+
+		_, err := ParseAttrs("shard=int:three")
+		require.ErrorContains(t, err, `invalid int value for attribute shard: "three"`)
+
+		_, err = ParseAttrs("shard=int:3,primary")
+		require.ErrorContains(t, err, `invalid attribute key "shard=int:3"`)
+	})
+
 }
 
 func getField0(t *testing.T, base any) reflect.StructField {
@@ -191,3 +279,938 @@ func TestTypeAndKindFor(t *testing.T) {
 		require.Equal(t, c.expectedKind, kind)
 	}
 }
+
+type observed struct {
+	Depth   I64ObservableGauge   `id:"queue_depth" callback:"ObserveDepth"`
+	Entries F64ObservableCounter `id:"cache_entries" callback:"ObserveEntries"`
+	Inner   sizer                `attrs:"inner,true"`
+
+	depth int64
+}
+
+type sizer struct {
+	Size I64ObservableUpDownCounter `id:"pool_size" callback:"ObserveSize"`
+}
+
+func (s *sizer) ObserveSize(_ context.Context, obs I64Observer) error {
+	obs.Observe(3)
+	return nil
+}
+
+func (o *observed) ObserveDepth(_ context.Context, obs I64Observer) error {
+	obs.Observe(o.depth)
+	return nil
+}
+
+func (o *observed) ObserveEntries(_ context.Context, obs F64Observer) error {
+	obs.Observe(1.5)
+	return nil
+}
+
+type leaky struct {
+	Depth   I64ObservableGauge `id:"leak_depth" callback:"ObserveDepth"`
+	Missing I64Counter
+}
+
+func (l *leaky) ObserveDepth(_ context.Context, obs I64Observer) error {
+	obs.Observe(1)
+	return nil
+}
+
+func TestObservable(t *testing.T) {
+	t.Parallel()
+	reader := m2.NewManualReader()
+	r := New(WithMeter(m2.NewMeterProvider(m2.WithReader(reader)).Meter("test")))
+
+	collect := func(t *testing.T) map[string]metricdata.Aggregation {
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		res := map[string]metricdata.Aggregation{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				res[m.Name] = m.Data
+			}
+		}
+		return res
+	}
+
+	t.Run("Fails when the callback method does not exist", func(t *testing.T) {
+		type invalid struct {
+			Gauge I64ObservableGauge `id:"invalid_gauge" callback:"Missing"`
+		}
+		_, err := InitIn[invalid](r)
+		require.ErrorContains(t, err, "Missing not found")
+	})
+
+	t.Run("Fails when the callback has an unexpected signature", func(t *testing.T) {
+		type invalid struct {
+			Gauge F64ObservableGauge `id:"invalid_gauge" callback:"ObserveSize"`
+			sizer
+		}
+		_, err := InitIn[invalid](r)
+		require.ErrorContains(t, err, "must be a func")
+	})
+
+	t.Run("Does not report observations of structs failing initialization", func(t *testing.T) {
+		_, err := InitIn[leaky](r)
+		require.ErrorContains(t, err, "Missing")
+		require.NotContains(t, collect(t), "leak_depth")
+
+		type elements struct {
+			Leaks []*leaky `len:"2"`
+		}
+		_, err = InitIn[elements](r)
+		require.ErrorContains(t, err, "Missing")
+		require.NotContains(t, collect(t), "leak_depth")
+	})
+
+	t.Run("Reports observations until closed", func(t *testing.T) {
+		s, err := InitIn[observed](r, attribute.String("layer", "1"))
+		require.NoError(t, err)
+		s.depth = 7
+
+		data := collect(t)
+		depth := data["queue_depth"].(metricdata.Gauge[int64])
+		require.Len(t, depth.DataPoints, 1)
+		require.Equal(t, int64(7), depth.DataPoints[0].Value)
+
+		entries := data["cache_entries"].(metricdata.Sum[float64])
+		require.Equal(t, 1.5, entries.DataPoints[0].Value)
+
+		size := data["pool_size"].(metricdata.Sum[int64])
+		require.Equal(t, int64(3), size.DataPoints[0].Value)
+		v, ok := size.DataPoints[0].Attributes.Value("inner")
+		require.True(t, ok)
+		require.Equal(t, "true", v.AsString())
+
+		require.NoError(t, Close(s))
+		require.NotContains(t, collect(t), "queue_depth")
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	type instruments struct {
+		Counter I64Counter `id:"registry_counter"`
+	}
+
+	newRegistry := func() (*Registry, *m2.ManualReader) {
+		reader := m2.NewManualReader()
+		return New(WithMeter(m2.NewMeterProvider(m2.WithReader(reader)).Meter("test"))), reader
+	}
+
+	sum := func(t *testing.T, reader *m2.ManualReader) int64 {
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		require.Len(t, rm.ScopeMetrics, 1)
+		return rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints[0].Value
+	}
+
+	t.Run("Registries record independently", func(t *testing.T) {
+		r1, reader1 := newRegistry()
+		r2, reader2 := newRegistry()
+
+		s1 := MustInitIn[instruments](r1)
+		s2 := MustInitIn[instruments](r2)
+		s1.Counter.Add(1)
+		s2.Counter.Add(2)
+
+		require.Equal(t, int64(1), sum(t, reader1))
+		require.Equal(t, int64(2), sum(t, reader2))
+	})
+
+	t.Run("Registries without a meter produce no-op instruments", func(t *testing.T) {
+		s, err := InitIn[instruments](New())
+		require.NoError(t, err)
+		require.NotPanics(t, func() { s.Counter.Add(1) })
+	})
+}
+
+func TestShutdown(t *testing.T) {
+	type instruments struct {
+		Counter I64Counter `id:"shutdown_counter"`
+	}
+
+	t.Run("Is a no-op for registries without a provider", func(t *testing.T) {
+		r := New()
+		require.NoError(t, r.ForceFlush(context.Background()))
+		require.NoError(t, r.Shutdown(context.Background()))
+	})
+
+	t.Run("Instruments degrade to no-ops after shutdown", func(t *testing.T) {
+		r := New()
+		require.NoError(t, r.Setup("test"))
+		s := MustInitIn[instruments](r)
+		counter := s.Counter.(*addImpl[int64])
+		require.NotNil(t, counter.inst.base.Load())
+
+		require.NoError(t, r.ForceFlush(context.Background()))
+		require.NoError(t, r.Shutdown(context.Background()))
+		require.Nil(t, counter.inst.base.Load())
+		require.NotPanics(t, func() { s.Counter.Add(1) })
+
+		require.NoError(t, r.Setup("test"))
+		require.NotNil(t, counter.inst.base.Load())
+		require.NoError(t, r.Shutdown(context.Background()))
+	})
+}
+
+func TestLateBinding(t *testing.T) {
+	t.Parallel()
+
+	r := New()
+	s, err := InitIn[observedLate](r)
+	require.NoError(t, err)
+	s.Counter.Add(1)
+
+	reader := m2.NewManualReader()
+	require.NoError(t, r.SetupWith("test", WithReader(reader)))
+	t.Cleanup(func() { require.NoError(t, r.Shutdown(context.Background())) })
+
+	s.Counter.Add(2)
+	s.Histogram.Record(1.5)
+
+	rm := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	data := map[string]metricdata.Aggregation{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		data[m.Name] = m.Data
+	}
+	require.Equal(t, int64(2), data["late_counter"].(metricdata.Sum[int64]).DataPoints[0].Value)
+	require.Equal(t, uint64(1), data["late_histogram"].(metricdata.Histogram[float64]).DataPoints[0].Count)
+	require.Equal(t, int64(4), data["late_gauge"].(metricdata.Gauge[int64]).DataPoints[0].Value)
+}
+
+func TestTracking(t *testing.T) {
+	t.Parallel()
+
+	r := New()
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, Close(MustInitIn[observedLate](r)))
+	}
+
+	tracked := func() int {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return len(r.delegates)
+	}
+	require.Eventually(t, func() bool {
+		runtime.GC()
+		return tracked() == 0
+	}, 5*time.Second, 10*time.Millisecond, "closed and dropped instruments are still tracked")
+
+	s := MustInitIn[observedLate](r)
+	require.Equal(t, 3, tracked())
+	require.NoError(t, Close(s))
+	require.Equal(t, 2, tracked())
+	runtime.KeepAlive(s)
+}
+
+type observedLate struct {
+	Counter   I64Counter         `id:"late_counter"`
+	Histogram F64Histogram       `id:"late_histogram" buckets:"1,2,3"`
+	Gauge     I64ObservableGauge `id:"late_gauge" callback:"Observe"`
+}
+
+func (o *observedLate) Observe(_ context.Context, obs I64Observer) error {
+	obs.Observe(4)
+	return nil
+}
+
+func TestSetupWith(t *testing.T) {
+	t.Parallel()
+
+	type instruments struct {
+		Counter I64Counter `id:"setup_counter"`
+	}
+
+	reader := m2.NewManualReader()
+	r := New()
+	err := r.SetupWith("test",
+		WithReader(reader),
+		WithResource(resource.NewSchemaless(attribute.String("service", "em"))),
+		WithAttributes(attribute.String("version", "1")),
+		WithView(m2.NewView(m2.Instrument{Name: "setup_counter"}, m2.Stream{Name: "renamed_counter"})),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, r.Shutdown(context.Background())) })
+
+	MustInitIn[instruments](r).Counter.Add(1)
+
+	rm := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	for _, k := range []attribute.Key{"service", "version"} {
+		_, ok := rm.Resource.Set().Value(k)
+		require.Truef(t, ok, "resource attribute %s not found", k)
+	}
+
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Equal(t, "renamed_counter", rm.ScopeMetrics[0].Metrics[0].Name)
+
+	t.Run("Fails on registries already set up", func(t *testing.T) {
+		require.ErrorIs(t, r.SetupWith("test", WithReader(m2.NewManualReader())), ErrAlreadySetup)
+		require.NoError(t, r.Setup("test"))
+	})
+}
+
+func TestDescriptionAndUnit(t *testing.T) {
+	t.Parallel()
+
+	type instruments struct {
+		Histogram F64Histogram `id:"latency" desc:"Request latency" unit:"ms"`
+		Counter   I64Counter   `id:"plain_counter"`
+	}
+
+	reader := m2.NewManualReader()
+	r := New(WithMeter(m2.NewMeterProvider(m2.WithReader(reader)).Meter("test")))
+	s := MustInitIn[instruments](r)
+	s.Histogram.Record(1)
+	s.Counter.Add(1)
+
+	rm := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	metrics := map[string]metricdata.Metrics{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+	require.Equal(t, "Request latency", metrics["latency"].Description)
+	require.Equal(t, "ms", metrics["latency"].Unit)
+	require.Empty(t, metrics["plain_counter"].Description)
+	require.Empty(t, metrics["plain_counter"].Unit)
+}
+
+func TestExponentialAggregation(t *testing.T) {
+	t.Parallel()
+
+	type instruments struct {
+		Exponential F64Histogram `id:"exp_latency" aggregation:"exponential" maxsize:"40" maxscale:"10"`
+		Defaults    I64Histogram `id:"exp_defaults" aggregation:"exponential"`
+		Explicit    F64Histogram `id:"explicit_latency" buckets:"1,2,3"`
+	}
+
+	t.Run("Does aggregate histograms as configured", func(t *testing.T) {
+		reader := m2.NewManualReader()
+		r := New()
+		s := MustInitIn[instruments](r)
+		require.NoError(t, r.SetupWith("test", WithReader(reader)))
+		s.Exponential.Record(1.5)
+		s.Defaults.Record(2)
+		s.Explicit.Record(2)
+
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+
+		metrics := map[string]metricdata.Metrics{}
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			metrics[m.Name] = m
+		}
+
+		exp, ok := metrics["exp_latency"].Data.(metricdata.ExponentialHistogram[float64])
+		require.True(t, ok)
+		require.Len(t, exp.DataPoints, 1)
+		require.Equal(t, uint64(1), exp.DataPoints[0].Count)
+		require.LessOrEqual(t, exp.DataPoints[0].Scale, int32(10))
+
+		_, ok = metrics["exp_defaults"].Data.(metricdata.ExponentialHistogram[int64])
+		require.True(t, ok)
+
+		explicit, ok := metrics["explicit_latency"].Data.(metricdata.Histogram[float64])
+		require.True(t, ok)
+		require.Equal(t, []float64{1, 2, 3}, explicit.DataPoints[0].Bounds)
+	})
+
+	t.Run("Fails with Prometheus as the only reader", func(t *testing.T) {
+		promOnly := WithPrometheus(prometheus.WithRegisterer(promclient.NewRegistry()))
+
+		r := New()
+		MustInitIn[instruments](r)
+		err := r.SetupWith("test", promOnly)
+		require.ErrorContains(t, err, "histograms exp_defaults, exp_latency use the exponential aggregation, which is not exported to Prometheus")
+		require.NoError(t, r.SetupWith("test", promOnly, WithReader(m2.NewManualReader())))
+		require.NoError(t, r.Shutdown(context.Background()))
+
+		r = New()
+		require.NoError(t, r.SetupWith("test", promOnly))
+		t.Cleanup(func() { require.NoError(t, r.Shutdown(context.Background())) })
+		_, err = InitIn[instruments](r)
+		require.ErrorContains(t, err, "histograms exp_latency use the exponential aggregation, which is not exported to Prometheus")
+	})
+
+	t.Run("Fails with invalid aggregation tags", func(t *testing.T) {
+		type unsupported struct {
+			H F64Histogram `id:"h" aggregation:"summary"`
+		}
+		_, err := InitIn[unsupported](New())
+		require.ErrorContains(t, err, "unsupported aggregation")
+
+		type withBuckets struct {
+			H F64Histogram `id:"h" aggregation:"exponential" buckets:"1,2"`
+		}
+		_, err = InitIn[withBuckets](New())
+		require.ErrorContains(t, err, "buckets cannot be used")
+
+		type sizeOnly struct {
+			H F64Histogram `id:"h" maxsize:"10"`
+		}
+		_, err = InitIn[sizeOnly](New())
+		require.Error(t, err)
+
+		for _, c := range [][2]string{{"0", ""}, {"a", ""}, {"", "21"}, {"", "-11"}} {
+			_, err = ParseAggregation("exponential", c[0], c[1])
+			require.Errorf(t, err, "expected maxsize %q and maxscale %q to fail", c[0], c[1])
+		}
+	})
+}
+
+func TestTimer(t *testing.T) {
+	t.Parallel()
+
+	type instruments struct {
+		Seconds Timer `id:"timer_seconds"`
+		Millis  Timer `id:"timer_millis" unit:"ms" buckets:"1,10,100"`
+	}
+
+	collect := func(t *testing.T, reader *m2.ManualReader) map[string]metricdata.Metrics {
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		metrics := map[string]metricdata.Metrics{}
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			metrics[m.Name] = m
+		}
+		return metrics
+	}
+
+	t.Run("Does record durations in the unit of the timer", func(t *testing.T) {
+		reader := m2.NewManualReader()
+		r := New(WithMeter(m2.NewMeterProvider(m2.WithReader(reader)).Meter("test")))
+		s := MustInitIn[instruments](r)
+
+		s.Seconds.RecordDuration(1500 * time.Millisecond)
+		s.Millis.RecordDuration(2 * time.Millisecond)
+
+		metrics := collect(t, reader)
+		seconds := metrics["timer_seconds"]
+		require.Equal(t, "s", seconds.Unit)
+		sPoints := seconds.Data.(metricdata.Histogram[float64]).DataPoints
+		require.Equal(t, 1.5, sPoints[0].Sum)
+		require.Equal(t, defaultSecondsBuckets, sPoints[0].Bounds)
+
+		millis := metrics["timer_millis"]
+		require.Equal(t, "ms", millis.Unit)
+		mPoints := millis.Data.(metricdata.Histogram[float64]).DataPoints
+		require.Equal(t, 2.0, mPoints[0].Sum)
+		require.Equal(t, []float64{1, 10, 100}, mPoints[0].Bounds)
+	})
+
+	t.Run("Does time calls", func(t *testing.T) {
+		reader := m2.NewManualReader()
+		r := New(WithMeter(m2.NewMeterProvider(m2.WithReader(reader)).Meter("test")))
+		s := MustInitIn[instruments](r, attribute.String("parent", "attr"))
+
+		stop := s.Millis.Start(metric.WithAttributes(attribute.String("op", "start")))
+		time.Sleep(time.Millisecond)
+		stop(metric.WithAttributes(attribute.String("status", "ok")))
+
+		s.Millis.Time(func() { time.Sleep(time.Millisecond) }, metric.WithAttributes(attribute.String("op", "time")))
+		s.Millis.Since(time.Now().Add(-time.Second), metric.WithAttributes(attribute.String("op", "since")))
+
+		points := collect(t, reader)["timer_millis"].Data.(metricdata.Histogram[float64]).DataPoints
+		require.Len(t, points, 3)
+		for _, p := range points {
+			require.Equal(t, uint64(1), p.Count)
+			require.GreaterOrEqual(t, p.Sum, 1.0)
+			v, ok := p.Attributes.Value("parent")
+			require.True(t, ok)
+			require.Equal(t, "attr", v.AsString())
+
+			op, _ := p.Attributes.Value("op")
+			if op.AsString() == "start" {
+				require.True(t, p.Attributes.HasValue("status"))
+			}
+			if op.AsString() == "since" {
+				require.GreaterOrEqual(t, p.Sum, 1000.0)
+			}
+		}
+	})
+
+	t.Run("Fails with unsupported units", func(t *testing.T) {
+		type invalid struct {
+			Timer Timer `id:"timer" unit:"us"`
+		}
+		_, err := InitIn[invalid](New())
+		require.ErrorContains(t, err, "unsupported unit")
+	})
+}
+
+func TestWith(t *testing.T) {
+	t.Parallel()
+
+	type instruments struct {
+		Counter   I64Counter   `id:"bound_counter"`
+		Histogram F64Histogram `id:"bound_histogram"`
+		Timer     Timer        `id:"bound_timer" unit:"ms"`
+	}
+
+	t.Run("Does record with parent and bound attributes", func(t *testing.T) {
+		reader := m2.NewManualReader()
+		r := New()
+		s := MustInitIn[instruments](r, attribute.String("parent", "attr"))
+
+		// Instruments bound before the registry is set up follow its meter.
+		get := s.Counter.With(attribute.String("method", "GET"))
+		require.NoError(t, r.SetupWith("test", WithReader(reader)))
+
+		get.Add(1)
+		get.With(attribute.Int("status", 200)).Add(2)
+		s.Counter.With(attribute.String("method", "POST")).Add(3, metric.WithAttributes(attribute.Bool("retry", true)))
+		s.Histogram.With(attribute.String("method", "GET")).Record(4)
+		s.Timer.With(attribute.String("method", "GET")).RecordDuration(5 * time.Millisecond)
+
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+
+		metrics := map[string]metricdata.Metrics{}
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			metrics[m.Name] = m
+		}
+
+		distinct := func(attrs ...attribute.KeyValue) attribute.Distinct {
+			set := attribute.NewSet(attrs...)
+			return set.Equivalent()
+		}
+		sums := map[attribute.Distinct]int64{}
+		for _, p := range metrics["bound_counter"].Data.(metricdata.Sum[int64]).DataPoints {
+			sums[p.Attributes.Equivalent()] = p.Value
+		}
+		parent := attribute.String("parent", "attr")
+		require.Equal(t, map[attribute.Distinct]int64{
+			distinct(parent, attribute.String("method", "GET")):                                 1,
+			distinct(parent, attribute.String("method", "GET"), attribute.Int("status", 200)):   2,
+			distinct(parent, attribute.String("method", "POST"), attribute.Bool("retry", true)): 3,
+		}, sums)
+
+		expected := attribute.NewSet(attribute.String("parent", "attr"), attribute.String("method", "GET"))
+		hPoints := metrics["bound_histogram"].Data.(metricdata.Histogram[float64]).DataPoints
+		require.Equal(t, expected, hPoints[0].Attributes)
+		tPoints := metrics["bound_timer"].Data.(metricdata.Histogram[float64]).DataPoints
+		require.Equal(t, expected, tPoints[0].Attributes)
+		require.Equal(t, 5.0, tPoints[0].Sum)
+	})
+}
+
+// TestWithAllocs can't run in parallel, as required by testing.AllocsPerRun.
+func TestWithAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not reliable under the race detector")
+	}
+
+	type instruments struct {
+		Counter   I64Counter   `id:"bound_counter"`
+		Histogram F64Histogram `id:"bound_histogram"`
+	}
+
+	reader := m2.NewManualReader()
+	r := New(WithMeter(m2.NewMeterProvider(m2.WithReader(reader)).Meter("test")))
+	s := MustInitIn[instruments](r, attribute.String("parent", "attr"))
+
+	counter := s.Counter.With(attribute.String("method", "GET"))
+	histogram := s.Histogram.With(attribute.String("method", "GET"))
+	counter.Add(1)
+	histogram.Record(1)
+
+	require.Zero(t, testing.AllocsPerRun(100, func() {
+		counter.Add(1)
+		histogram.Record(1)
+	}))
+}
+
+type (
+	requestCounter I64Counter
+	queueSize      I64UpDownCounter
+	latency        F64Histogram
+	requestTimer   Timer
+	connections    I64ObservableGauge
+	histogramAlias = F64Histogram
+)
+
+type domainTyped struct {
+	Requests    requestCounter `id:"domain_requests" kind:"counter"`
+	Queue       queueSize      `id:"domain_queue" kind:"updown"`
+	Latency     latency        `id:"domain_latency" kind:"h" buckets:"1,2"`
+	Timer       requestTimer   `id:"domain_timer"`
+	Alias       histogramAlias `id:"domain_alias"`
+	Connections connections    `id:"domain_connections" kind:"gauge" callback:"ObserveConnections"`
+}
+
+func (d *domainTyped) ObserveConnections(_ context.Context, o I64Observer) error {
+	o.Observe(3)
+	return nil
+}
+
+func TestKind(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Does initialize types defined on top of em ones", func(t *testing.T) {
+		reader := m2.NewManualReader()
+		r := New(WithMeter(m2.NewMeterProvider(m2.WithReader(reader)).Meter("test")))
+		s := MustInitIn[domainTyped](r)
+		s.Requests.Add(1)
+		s.Queue.Add(-1)
+		s.Latency.Record(1)
+		s.Timer.RecordDuration(time.Second)
+		s.Alias.Record(1)
+
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+
+		metrics := map[string]metricdata.Metrics{}
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			metrics[m.Name] = m
+		}
+		require.True(t, metrics["domain_requests"].Data.(metricdata.Sum[int64]).IsMonotonic)
+		require.False(t, metrics["domain_queue"].Data.(metricdata.Sum[int64]).IsMonotonic)
+		require.Equal(t, []float64{1, 2}, metrics["domain_latency"].Data.(metricdata.Histogram[float64]).DataPoints[0].Bounds)
+		require.Equal(t, "s", metrics["domain_timer"].Unit)
+		require.IsType(t, metricdata.Histogram[float64]{}, metrics["domain_alias"].Data)
+		require.Equal(t, int64(3), metrics["domain_connections"].Data.(metricdata.Gauge[int64]).DataPoints[0].Value)
+	})
+
+	t.Run("Fails on ambiguous kinds", func(t *testing.T) {
+		type ambiguous struct {
+			Requests requestCounter `id:"requests"`
+		}
+		_, err := InitIn[ambiguous](New())
+		require.ErrorContains(t, err, "ambiguous kind for field Requests")
+		require.ErrorContains(t, err, "I64Counter, I64UpDownCounter")
+
+		type ambiguousObservable struct {
+			Connections connections `id:"connections" callback:"ObserveConnections"`
+		}
+		_, err = InitIn[ambiguousObservable](New())
+		require.ErrorContains(t, err, "ambiguous kind for field Connections")
+	})
+
+	t.Run("Fails on kinds not applying to the type", func(t *testing.T) {
+		type conflicting struct {
+			Counter I64Counter `id:"counter" kind:"gauge"`
+		}
+		_, err := InitIn[conflicting](New())
+		require.ErrorContains(t, err, `kind "gauge" does not apply to I64Counter`)
+
+		type unknown struct {
+			Counter requestCounter `id:"counter" kind:"summary"`
+		}
+		_, err = InitIn[unknown](New())
+		require.ErrorContains(t, err, `unsupported kind "summary"`)
+	})
+
+	t.Run("Fails on types extending em ones", func(t *testing.T) {
+		type extended struct {
+			Counter extendedCounter `id:"counter"`
+		}
+		_, err := InitIn[extended](New())
+		require.ErrorContains(t, err, "unsupported instrument type em.extendedCounter for field Counter")
+
+		type extendedKind struct {
+			Counter extendedCounter `id:"counter" kind:"counter"`
+		}
+		_, err = InitIn[extendedKind](New())
+		require.ErrorContains(t, err, "unsupported instrument type em.extendedCounter for field Counter")
+	})
+}
+
+type extendedCounter interface {
+	I64Counter
+	Extra()
+}
+
+type route string
+
+func TestFamily(t *testing.T) {
+	t.Parallel()
+
+	type instruments struct {
+		PerRoute  Family[route, I64Counter]    `id:"family_requests" key:"route" maxkeys:"2"`
+		PerShard  Family[int, F64Histogram]    `id:"family_latency" key:"shard" buckets:"1,2"`
+		PerTenant Family[string, Timer]        `id:"family_timer" key:"tenant" unit:"ms"`
+		Domain    Family[bool, requestCounter] `id:"family_domain" key:"cached" kind:"counter"`
+	}
+
+	t.Run("Does create an instrument per key", func(t *testing.T) {
+		reader := m2.NewManualReader()
+		r := New()
+		s := MustInitIn[instruments](r, attribute.String("parent", "attr"))
+		require.NoError(t, r.SetupWith("test", WithReader(reader)))
+
+		s.PerRoute.Get("/a").Add(1)
+		s.PerRoute.Get("/a").Add(1)
+		s.PerRoute.Get("/b").Add(1)
+		// Keys beyond maxkeys share the overflow instrument.
+		s.PerRoute.Get("/c").Add(1)
+		s.PerRoute.Get("/d").Add(1)
+		s.PerShard.Get(3).Record(1.5)
+		s.PerTenant.Get("acme").RecordDuration(time.Millisecond)
+		s.Domain.Get(true).Add(1)
+
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+
+		metrics := map[string]metricdata.Metrics{}
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			metrics[m.Name] = m
+		}
+
+		parent := attribute.String("parent", "attr")
+		sums := map[attribute.Set]int64{}
+		for _, p := range metrics["family_requests"].Data.(metricdata.Sum[int64]).DataPoints {
+			sums[p.Attributes] = p.Value
+		}
+		require.Equal(t, map[attribute.Set]int64{
+			attribute.NewSet(parent, attribute.String("route", "/a")):              2,
+			attribute.NewSet(parent, attribute.String("route", "/b")):              1,
+			attribute.NewSet(parent, attribute.Bool("otel.metric.overflow", true)): 2,
+		}, sums)
+
+		shard := metrics["family_latency"].Data.(metricdata.Histogram[float64]).DataPoints[0]
+		require.Equal(t, attribute.NewSet(parent, attribute.Int("shard", 3)), shard.Attributes)
+		require.Equal(t, []float64{1, 2}, shard.Bounds)
+
+		tenant := metrics["family_timer"].Data.(metricdata.Histogram[float64]).DataPoints[0]
+		require.Equal(t, attribute.NewSet(parent, attribute.String("tenant", "acme")), tenant.Attributes)
+		require.Equal(t, 1.0, tenant.Sum)
+
+		domain := metrics["family_domain"].Data.(metricdata.Sum[int64]).DataPoints[0]
+		require.Equal(t, attribute.NewSet(parent, attribute.Bool("cached", true)), domain.Attributes)
+	})
+
+	t.Run("Fails with invalid families", func(t *testing.T) {
+		type missingKey struct {
+			F Family[string, I64Counter] `id:"f"`
+		}
+		_, err := InitIn[missingKey](New())
+		require.ErrorContains(t, err, "missing key tag for field F")
+
+		type invalidMax struct {
+			F Family[string, I64Counter] `id:"f" key:"k" maxkeys:"-1"`
+		}
+		_, err = InitIn[invalidMax](New())
+		require.ErrorContains(t, err, "invalid maxkeys")
+
+		type observableFamily struct {
+			F Family[string, I64ObservableGauge] `id:"f" key:"k" callback:"Observe"`
+		}
+		_, err = InitIn[observableFamily](New())
+		require.ErrorContains(t, err, "observable instruments are not supported")
+
+		type unsupported struct {
+			F Family[string, string] `id:"f" key:"k"`
+		}
+		_, err = InitIn[unsupported](New())
+		require.ErrorContains(t, err, "unsupported instrument type")
+	})
+
+	t.Run("Does write unsigned keys as integers when they fit", func(t *testing.T) {
+		require.Equal(t, attribute.Int64("k", 7), keyAttr("k", uint8(7)))
+		require.Equal(t, attribute.Int64("k", 7), keyAttr("k", uint(7)))
+		require.Equal(t, attribute.Int64("k", 7), keyAttr("k", uint64(7)))
+		require.Equal(t, attribute.Int64("k", 7), keyAttr("k", uintptr(7)))
+		require.Equal(t, attribute.Int64("k", math.MaxInt64), keyAttr("k", uint64(math.MaxInt64)))
+		require.Equal(t, attribute.String("k", "18446744073709551615"), keyAttr("k", uint64(math.MaxUint64)))
+	})
+}
+
+type worker struct {
+	Processed I64Counter         `id:"worker_processed"`
+	Busy      I64ObservableGauge `id:"worker_busy" callback:"ObserveBusy"`
+}
+
+func (w *worker) ObserveBusy(_ context.Context, o I64Observer) error {
+	o.Observe(1)
+	return nil
+}
+
+func TestElements(t *testing.T) {
+	t.Parallel()
+
+	type instruments struct {
+		Workers [2]worker `index:"worker" attrs:"pool,main"`
+		Shards  []*struct {
+			Requests I64Counter `id:"shard_requests"`
+		} `len:"3"`
+		Ignored []worker
+	}
+
+	t.Run("Does initialize elements with their index", func(t *testing.T) {
+		reader := m2.NewManualReader()
+		r := New(WithMeter(m2.NewMeterProvider(m2.WithReader(reader)).Meter("test")))
+		s := MustInitIn[instruments](r, attribute.String("parent", "attr"))
+		require.Len(t, s.Shards, 3)
+		require.Nil(t, s.Ignored)
+
+		for i := range s.Workers {
+			s.Workers[i].Processed.Add(int64(i + 1))
+		}
+		for i, shard := range s.Shards {
+			shard.Requests.Add(int64(i + 1))
+		}
+
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+
+		metrics := map[string]metricdata.Metrics{}
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			metrics[m.Name] = m
+		}
+
+		parent := attribute.String("parent", "attr")
+		pool := attribute.String("pool", "main")
+		values := func(name string) map[attribute.Set]int64 {
+			res := map[attribute.Set]int64{}
+			switch data := metrics[name].Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, p := range data.DataPoints {
+					res[p.Attributes] = p.Value
+				}
+			case metricdata.Gauge[int64]:
+				for _, p := range data.DataPoints {
+					res[p.Attributes] = p.Value
+				}
+			}
+			return res
+		}
+
+		require.Equal(t, map[attribute.Set]int64{
+			attribute.NewSet(parent, pool, attribute.Int("worker", 0)): 1,
+			attribute.NewSet(parent, pool, attribute.Int("worker", 1)): 2,
+		}, values("worker_processed"))
+		require.Equal(t, map[attribute.Set]int64{
+			attribute.NewSet(parent, pool, attribute.Int("worker", 0)): 1,
+			attribute.NewSet(parent, pool, attribute.Int("worker", 1)): 1,
+		}, values("worker_busy"))
+		require.Equal(t, map[attribute.Set]int64{
+			attribute.NewSet(parent, attribute.Int("index", 0)): 1,
+			attribute.NewSet(parent, attribute.Int("index", 1)): 2,
+			attribute.NewSet(parent, attribute.Int("index", 2)): 3,
+		}, values("shard_requests"))
+
+		require.NoError(t, Close(s))
+		rm = metricdata.ResourceMetrics{}
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			if m.Name == "worker_busy" {
+				require.Empty(t, m.Data.(metricdata.Gauge[int64]).DataPoints)
+			}
+		}
+	})
+
+	t.Run("Fails with invalid lengths", func(t *testing.T) {
+		type invalid struct {
+			Workers []worker `len:"many"`
+		}
+		_, err := InitIn[invalid](New())
+		require.ErrorContains(t, err, `invalid len "many" on field Workers`)
+	})
+}
+
+type pool struct {
+	Open  I64UpDownCounter `id:"open"`
+	Waits Timer            `id:"wait"`
+}
+
+func TestPrefix(t *testing.T) {
+	t.Parallel()
+
+	type instruments struct {
+		Primary  pool  `prefix:"db_primary_"`
+		Replica  *pool `prefix:"db_replica_"`
+		Services struct {
+			Users pool `prefix:"users_"`
+			Queue struct {
+				Depth    I64Gauge                   `id:"depth"`
+				PerTopic Family[string, I64Counter] `id:"messages" key:"topic"`
+			} `prefix:"queue_"`
+		} `prefix:"svc_"`
+		Shards [1]pool `prefix:"shard_"`
+	}
+
+	names := func(r *Registry, reader m2.Reader) []string {
+		s := MustInitIn[instruments](r)
+		s.Primary.Open.Add(1)
+		s.Replica.Open.Add(1)
+		s.Services.Users.Open.Add(1)
+		s.Services.Users.Waits.RecordDuration(time.Second)
+		s.Services.Queue.Depth.Record(1)
+		s.Services.Queue.PerTopic.Get("jobs").Add(1)
+		s.Shards[0].Open.Add(1)
+
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		var res []string
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			res = append(res, m.Name)
+		}
+		return res
+	}
+
+	t.Run("Does prepend the prefixes of enclosing structs", func(t *testing.T) {
+		reader := m2.NewManualReader()
+		r := New(WithMeter(m2.NewMeterProvider(m2.WithReader(reader)).Meter("test")))
+		require.ElementsMatch(t, []string{
+			"db_primary_open",
+			"db_replica_open",
+			"svc_users_open",
+			"svc_users_wait",
+			"svc_queue_depth",
+			"svc_queue_messages",
+			"shard_open",
+		}, names(r, reader))
+	})
+
+	t.Run("Does prepend the registry namespace", func(t *testing.T) {
+		reader := m2.NewManualReader()
+		r := New(WithMeter(m2.NewMeterProvider(m2.WithReader(reader)).Meter("test")), WithNamespace("app_"))
+		require.ElementsMatch(t, []string{
+			"app_db_primary_open",
+			"app_db_replica_open",
+			"app_svc_users_open",
+			"app_svc_users_wait",
+			"app_svc_queue_depth",
+			"app_svc_queue_messages",
+			"app_shard_open",
+		}, names(r, reader))
+	})
+
+	t.Run("Does prepend the namespace of configured registries", func(t *testing.T) {
+		reader := m2.NewManualReader()
+		r := New(WithMeter(m2.NewMeterProvider(m2.WithReader(reader)).Meter("test")))
+		require.NoError(t, r.Configure(WithNamespace("app_")))
+		require.Contains(t, names(r, reader), "app_shard_open")
+	})
+
+	t.Run("Fails to configure registries with instruments", func(t *testing.T) {
+		r := New()
+		MustInitIn[instruments](r)
+		require.ErrorContains(t, r.Configure(WithNamespace("app_")), "registry already has instruments")
+	})
+
+	t.Run("Does read the namespace while registries are configured", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			r := New()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				_ = r.Configure(WithNamespace("app_"))
+			}()
+			_, err := DescribeIn[instruments](r)
+			require.NoError(t, err)
+			_, err = InitIn[instruments](r)
+			require.NoError(t, err)
+			<-done
+		}
+	})
+}
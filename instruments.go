@@ -2,6 +2,9 @@ package em
 
 import (
 	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
@@ -18,11 +21,27 @@ type baseRecord[T any] interface {
 type add[T any] interface {
 	Add(n T, opts ...metric.AddOption)
 	AddCtx(ctx context.Context, n T, opts ...metric.AddOption)
+	// With returns the instrument bound to the given attributes, in addition
+	// to those of its struct. The attribute set is computed once, so
+	// measurements made through the bound instrument without options do not
+	// allocate.
+	With(attrs ...attribute.KeyValue) add[T]
 }
 
 type record[T any] interface {
 	Record(n T, opts ...metric.RecordOption)
 	RecordCtx(ctx context.Context, n T, opts ...metric.RecordOption)
+	// With returns the instrument bound to the given attributes, as for
+	// counters.
+	With(attrs ...attribute.KeyValue) record[T]
+}
+
+type observe[T any] interface {
+	Observe(n T, opts ...metric.ObserveOption)
+}
+
+type observable interface {
+	Unregister() error
 }
 
 type I64Counter add[int64]
@@ -41,14 +60,106 @@ type F64Gauge record[float64]
 
 type F64Histogram record[float64]
 
+// I64Observer and F64Observer are handed to the callbacks of observable
+// instruments. Observations made through them carry the attributes of the
+// instrument struct they belong to.
+type I64Observer observe[int64]
+
+type F64Observer observe[float64]
+
+// Observable instruments are reported through a callback method declared on
+// the struct holding them and named by the 'callback' tag. Callbacks must
+// have the signature func(context.Context, I64Observer) error (or
+// F64Observer, for float64 instruments).
+type I64ObservableCounter observable
+
+type I64ObservableUpDownCounter observable
+
+type I64ObservableGauge observable
+
+type F64ObservableCounter observable
+
+type F64ObservableUpDownCounter observable
+
+type F64ObservableGauge observable
+
+// binding holds the instrument created by the meter its registry currently
+// holds. While the registry has no meter, it holds nil.
+type binding[I any] struct {
+	base  atomic.Pointer[I]
+	build func(metric.Meter) (I, error)
+}
+
+func (b *binding[I]) bind(m metric.Meter) error {
+	if m == nil {
+		b.base.Store(nil)
+		return nil
+	}
+
+	base, err := b.build(m)
+	if err != nil {
+		return err
+	}
+	b.base.Store(&base)
+	return nil
+}
+
+// bound is how instruments reference their binding. Registries track the
+// binding itself, so that once every instrument sharing a bound is dropped,
+// the bound is finalized and its binding stops being tracked.
+type bound[I any] struct {
+	*binding[I]
+}
+
+func newBound[I any](r *Registry, build func(metric.Meter) (I, error)) (*bound[I], error) {
+	b := &binding[I]{build: build}
+	if err := r.track(b); err != nil {
+		return nil, err
+	}
+	h := &bound[I]{b}
+	runtime.SetFinalizer(h, func(h *bound[I]) {
+		r.untrack(h.binding)
+	})
+	return h, nil
+}
+
+// addImpl and recordImpl record through a binding, discarding measurements
+// while it holds no instrument. Their attributes are turned into a single
+// option holding a precomputed set, and merged with call-site options through
+// pooled slices, so that recording costs no allocation over the instrument.
+// Those derived through With share the binding of their parent.
 type addImpl[T any] struct {
-	baseAdd[T]
-	parentAttrs []attribute.KeyValue
+	inst  *bound[baseAdd[T]]
+	attrs []attribute.KeyValue
+	opts  []metric.AddOption
 }
 
 type recordImpl[T any] struct {
-	baseRecord[T]
+	inst  *bound[baseRecord[T]]
 	attrs []attribute.KeyValue
+	opts  []metric.RecordOption
+}
+
+var (
+	addOptions     = sync.Pool{New: func() any { return new([]metric.AddOption) }}
+	recordOptions  = sync.Pool{New: func() any { return new([]metric.RecordOption) }}
+	observeOptions = sync.Pool{New: func() any { return new([]metric.ObserveOption) }}
+)
+
+func newAddImpl[T any](inst *bound[baseAdd[T]], attrs []attribute.KeyValue) *addImpl[T] {
+	a := &addImpl[T]{inst: inst, attrs: attrs}
+	if len(attrs) > 0 {
+		a.opts = []metric.AddOption{metric.WithAttributeSet(attribute.NewSet(attrs...))}
+	}
+	return a
+}
+
+func newRecordImpl[T any](inst *bound[baseRecord[T]], attrs []attribute.KeyValue) *recordImpl[T] {
+	r := &recordImpl[T]{inst: inst, attrs: attrs}
+	if len(attrs) > 0 {
+		r.opts = []metric.RecordOption{metric.WithAttributeSet(attribute.NewSet(attrs...))}
+	}
+	return r
 }
 
 func (a *addImpl[T]) Add(n T, opts ...metric.AddOption) {
@@ -56,8 +167,28 @@ func (a *addImpl[T]) Add(n T, opts ...metric.AddOption) {
 }
 
 func (a *addImpl[T]) AddCtx(ctx context.Context, n T, opts ...metric.AddOption) {
-	o := append([]metric.AddOption{metric.WithAttributes(a.parentAttrs...)}, opts...)
-	a.baseAdd.Add(ctx, n, o...)
+	base := a.inst.base.Load()
+	if base == nil {
+		return
+	}
+
+	switch {
+	case len(opts) == 0:
+		(*base).Add(ctx, n, a.opts...)
+	case len(a.opts) == 0:
+		(*base).Add(ctx, n, opts...)
+	default:
+		buf := addOptions.Get().(*[]metric.AddOption)
+		*buf = append(append(*buf, a.opts...), opts...)
+		(*base).Add(ctx, n, *buf...)
+		clear(*buf)
+		*buf = (*buf)[:0]
+		addOptions.Put(buf)
+	}
+}
+
+func (a *addImpl[T]) With(attrs ...attribute.KeyValue) add[T] {
+	return newAddImpl(a.inst, append(a.attrs[:len(a.attrs):len(a.attrs)], attrs...))
 }
 
 func (r *recordImpl[T]) Record(n T, opts ...metric.RecordOption) {
@@ -65,104 +196,268 @@ func (r *recordImpl[T]) Record(n T, opts ...metric.RecordOption) {
 }
 
 func (r *recordImpl[T]) RecordCtx(ctx context.Context, n T, opts ...metric.RecordOption) {
-	o := append([]metric.RecordOption{metric.WithAttributes(r.attrs...)}, opts...)
-	r.baseRecord.Record(ctx, n, o...)
-}
+	base := r.inst.base.Load()
+	if base == nil {
+		return
+	}
 
-func (p *provider) i64c(kind, id string, attrs ...attribute.KeyValue) (add[int64], error) {
-	if p == nil {
-		return new(nilProv[int64]), nil
+	switch {
+	case len(opts) == 0:
+		(*base).Record(ctx, n, r.opts...)
+	case len(r.opts) == 0:
+		(*base).Record(ctx, n, opts...)
+	default:
+		buf := recordOptions.Get().(*[]metric.RecordOption)
+		*buf = append(append(*buf, r.opts...), opts...)
+		(*base).Record(ctx, n, *buf...)
+		clear(*buf)
+		*buf = (*buf)[:0]
+		recordOptions.Put(buf)
 	}
+}
 
-	var (
-		base baseAdd[int64]
-		err  error
-	)
+func (r *recordImpl[T]) With(attrs ...attribute.KeyValue) record[T] {
+	return newRecordImpl(r.inst, append(r.attrs[:len(r.attrs):len(r.attrs)], attrs...))
+}
 
-	switch kind {
-	case counter:
-		base, err = prov.m.Int64Counter(id)
-	case upDownCounter:
-		base, err = prov.m.Int64UpDownCounter(id)
+func (r *Registry) i64c(kind string, spec Spec, attrs ...attribute.KeyValue) (add[int64], error) {
+	if err := r.register(i64Type, kind, spec); err != nil {
+		return nil, err
 	}
+	inst, err := newBound(r, func(m metric.Meter) (baseAdd[int64], error) {
+		if kind == upDownCounter {
+			return m.Int64UpDownCounter(spec.ID, spec.description(), spec.unit())
+		}
+		return m.Int64Counter(spec.ID, spec.description(), spec.unit())
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	return &addImpl[int64]{base, attrs}, nil
+	return newAddImpl(inst, attrs), nil
 }
 
-func (p *provider) f64c(kind, id string, attrs ...attribute.KeyValue) (add[float64], error) {
-	if p == nil {
-		return new(nilProv[float64]), nil
+func (r *Registry) f64c(kind string, spec Spec, attrs ...attribute.KeyValue) (add[float64], error) {
+	if err := r.register(f64Type, kind, spec); err != nil {
+		return nil, err
 	}
+	inst, err := newBound(r, func(m metric.Meter) (baseAdd[float64], error) {
+		if kind == upDownCounter {
+			return m.Float64UpDownCounter(spec.ID, spec.description(), spec.unit())
+		}
+		return m.Float64Counter(spec.ID, spec.description(), spec.unit())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newAddImpl(inst, attrs), nil
+}
 
-	var (
-		base baseAdd[float64]
-		err  error
-	)
-	switch kind {
-	case counter:
-		base, err = prov.m.Float64Counter(id)
-	case upDownCounter:
-		base, err = prov.m.Float64UpDownCounter(id)
+func (r *Registry) i64r(kind string, spec Spec, attrs ...attribute.KeyValue) (record[int64], error) {
+	if err := r.register(i64Type, kind, spec); err != nil {
+		return nil, err
+	}
+	if kind == histogram {
+		if err := r.setAggregation(spec); err != nil {
+			return nil, err
+		}
 	}
+	inst, err := newBound(r, func(m metric.Meter) (baseRecord[int64], error) {
+		if kind == histogram {
+			return m.Int64Histogram(spec.ID, metric.WithExplicitBucketBoundaries(spec.Buckets...), spec.description(), spec.unit())
+		}
+		return m.Int64Gauge(spec.ID, spec.description(), spec.unit())
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	return &addImpl[float64]{base, attrs}, nil
+	return newRecordImpl(inst, attrs), nil
 }
 
-func (p *provider) i64r(kind, id string, bounds []float64, attrs ...attribute.KeyValue) (record[int64], error) {
-	if p == nil {
-		return new(nilProv[int64]), nil
+func (r *Registry) f64r(kind string, spec Spec, attrs ...attribute.KeyValue) (record[float64], error) {
+	if err := r.register(f64Type, kind, spec); err != nil {
+		return nil, err
 	}
-	var (
-		base baseRecord[int64]
-		err  error
-	)
-	switch kind {
-	case gauge:
-		base, err = prov.m.Int64Gauge(id)
-	case histogram:
-		base, err = prov.m.Int64Histogram(id, metric.WithExplicitBucketBoundaries(bounds...))
+	if kind == histogram {
+		if err := r.setAggregation(spec); err != nil {
+			return nil, err
+		}
 	}
-
+	inst, err := newBound(r, func(m metric.Meter) (baseRecord[float64], error) {
+		if kind == histogram {
+			return m.Float64Histogram(spec.ID, metric.WithExplicitBucketBoundaries(spec.Buckets...), spec.description(), spec.unit())
+		}
+		return m.Float64Gauge(spec.ID, spec.description(), spec.unit())
+	})
 	if err != nil {
 		return nil, err
 	}
-	return &recordImpl[int64]{base, attrs}, nil
+	return newRecordImpl(inst, attrs), nil
+}
+
+// observableImpl keeps the callback of an observable instrument registered
+// with the meter its registry currently holds, until it is unregistered.
+type observableImpl struct {
+	r      *Registry
+	mu     sync.Mutex
+	reg    metric.Registration
+	build  func(metric.Meter) (metric.Registration, error)
+	closed bool
 }
 
-func (p *provider) f64r(kind, id string, bounds []float64, attrs ...attribute.KeyValue) (record[float64], error) {
-	if p == nil {
-		return new(nilProv[float64]), nil
+// Unregister unregisters the callback, and stops tracking the instrument in
+// its registry. It must not hold mu meanwhile, as the registry lock is held
+// while binding.
+func (o *observableImpl) Unregister() error {
+	o.mu.Lock()
+	o.closed = true
+	err := o.unregister()
+	o.mu.Unlock()
+
+	o.r.untrack(o)
+	return err
+}
+
+func (o *observableImpl) unregister() error {
+	if o.reg == nil {
+		return nil
 	}
-	var (
-		base baseRecord[float64]
-		err  error
-	)
-	switch kind {
-	case gauge:
-		base, err = prov.m.Float64Gauge(id)
-	case histogram:
-		base, err = prov.m.Float64Histogram(id, metric.WithExplicitBucketBoundaries(bounds...))
+	err := o.reg.Unregister()
+	o.reg = nil
+	return err
+}
+
+func (o *observableImpl) bind(m metric.Meter) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return nil
 	}
 
+	if err := o.unregister(); err != nil {
+		return err
+	}
+	if m == nil {
+		return nil
+	}
+
+	reg, err := o.build(m)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	o.reg = reg
+	return nil
+}
+
+// i64Observer and f64Observer observe with the option holding the
+// precomputed attribute set of their instrument, merged with call-site options
+// as addImpl does.
+type i64Observer struct {
+	o    metric.Observer
+	inst metric.Int64Observable
+	opts []metric.ObserveOption
+}
 
-	return &recordImpl[float64]{base, attrs}, nil
+func (i *i64Observer) Observe(n int64, opts ...metric.ObserveOption) {
+	switch {
+	case len(opts) == 0:
+		i.o.ObserveInt64(i.inst, n, i.opts...)
+	case len(i.opts) == 0:
+		i.o.ObserveInt64(i.inst, n, opts...)
+	default:
+		buf := observeOptions.Get().(*[]metric.ObserveOption)
+		*buf = append(append(*buf, i.opts...), opts...)
+		i.o.ObserveInt64(i.inst, n, *buf...)
+		clear(*buf)
+		*buf = (*buf)[:0]
+		observeOptions.Put(buf)
+	}
 }
 
-type nilProv[T any] struct{}
+type f64Observer struct {
+	o    metric.Observer
+	inst metric.Float64Observable
+	opts []metric.ObserveOption
+}
 
-func (n2 nilProv[T]) Add(T, ...metric.AddOption) {}
+func (f *f64Observer) Observe(n float64, opts ...metric.ObserveOption) {
+	switch {
+	case len(opts) == 0:
+		f.o.ObserveFloat64(f.inst, n, f.opts...)
+	case len(f.opts) == 0:
+		f.o.ObserveFloat64(f.inst, n, opts...)
+	default:
+		buf := observeOptions.Get().(*[]metric.ObserveOption)
+		*buf = append(append(*buf, f.opts...), opts...)
+		f.o.ObserveFloat64(f.inst, n, *buf...)
+		clear(*buf)
+		*buf = (*buf)[:0]
+		observeOptions.Put(buf)
+	}
+}
 
-func (n2 nilProv[T]) AddCtx(context.Context, T, ...metric.AddOption) {}
+func newObserveOptions(attrs []attribute.KeyValue) []metric.ObserveOption {
+	if len(attrs) == 0 {
+		return nil
+	}
+	return []metric.ObserveOption{metric.WithAttributeSet(attribute.NewSet(attrs...))}
+}
 
-func (n2 nilProv[T]) Record(T, ...metric.RecordOption) {}
+func (r *Registry) i64o(kind string, spec Spec, cb func(context.Context, I64Observer) error, attrs ...attribute.KeyValue) (observable, error) {
+	if err := r.register(i64Type, kind, spec); err != nil {
+		return nil, err
+	}
+	opts := newObserveOptions(attrs)
+	o := &observableImpl{r: r}
+	o.build = func(m metric.Meter) (metric.Registration, error) {
+		var (
+			inst metric.Int64Observable
+			err  error
+		)
+		switch kind {
+		case observableCounter:
+			inst, err = m.Int64ObservableCounter(spec.ID, spec.description(), spec.unit())
+		case observableUpDownCounter:
+			inst, err = m.Int64ObservableUpDownCounter(spec.ID, spec.description(), spec.unit())
+		case observableGauge:
+			inst, err = m.Int64ObservableGauge(spec.ID, spec.description(), spec.unit())
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return m.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+			return cb(ctx, &i64Observer{o, inst, opts})
+		}, inst)
+	}
+	return o, r.track(o)
+}
 
-func (n2 nilProv[T]) RecordCtx(context.Context, T, ...metric.RecordOption) {}
+func (r *Registry) f64o(kind string, spec Spec, cb func(context.Context, F64Observer) error, attrs ...attribute.KeyValue) (observable, error) {
+	if err := r.register(f64Type, kind, spec); err != nil {
+		return nil, err
+	}
+	opts := newObserveOptions(attrs)
+	o := &observableImpl{r: r}
+	o.build = func(m metric.Meter) (metric.Registration, error) {
+		var (
+			inst metric.Float64Observable
+			err  error
+		)
+		switch kind {
+		case observableCounter:
+			inst, err = m.Float64ObservableCounter(spec.ID, spec.description(), spec.unit())
+		case observableUpDownCounter:
+			inst, err = m.Float64ObservableUpDownCounter(spec.ID, spec.description(), spec.unit())
+		case observableGauge:
+			inst, err = m.Float64ObservableGauge(spec.ID, spec.description(), spec.unit())
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return m.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+			return cb(ctx, &f64Observer{o, inst, opts})
+		}, inst)
+	}
+	return o, r.track(o)
+}
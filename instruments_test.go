@@ -0,0 +1,173 @@
+package em
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	m2 "go.opentelemetry.io/otel/sdk/metric"
+)
+
+type benchInstruments struct {
+	Counter   I64Counter   `id:"bench_counter"`
+	Histogram F64Histogram `id:"bench_histogram"`
+}
+
+// benchAttrs returns n attributes, as set on the parent of instruments.
+func benchAttrs(n int) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, n)
+	for i := 0; i < n; i++ {
+		attrs = append(attrs, attribute.Int(fmt.Sprintf("attr_%d", i), i))
+	}
+	return attrs
+}
+
+func benchMeter() metric.Meter {
+	return m2.NewMeterProvider(m2.WithReader(m2.NewManualReader())).Meter("bench")
+}
+
+var benchSizes = []int{0, 1, 8}
+
+// BenchmarkAdd compares em counters to OTEL counters recording the same
+// attributes as a precomputed set, the cheapest way of using the latter.
+func BenchmarkAdd(b *testing.B) {
+	ctx := context.Background()
+	callSite := metric.WithAttributeSet(attribute.NewSet(attribute.String("call", "site")))
+
+	for _, n := range benchSizes {
+		attrs := benchAttrs(n)
+
+		b.Run(fmt.Sprintf("otel/%d", n), func(b *testing.B) {
+			counter, err := benchMeter().Int64Counter("bench_counter")
+			require.NoError(b, err)
+			opt := metric.WithAttributeSet(attribute.NewSet(attrs...))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				counter.Add(ctx, 1, opt)
+			}
+		})
+
+		b.Run(fmt.Sprintf("em/%d", n), func(b *testing.B) {
+			s := MustInitIn[benchInstruments](New(WithMeter(benchMeter())), attrs...)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s.Counter.AddCtx(ctx, 1)
+			}
+		})
+
+		b.Run(fmt.Sprintf("otel_call_site/%d", n), func(b *testing.B) {
+			counter, err := benchMeter().Int64Counter("bench_counter")
+			require.NoError(b, err)
+			opt := metric.WithAttributeSet(attribute.NewSet(attrs...))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				counter.Add(ctx, 1, opt, callSite)
+			}
+		})
+
+		b.Run(fmt.Sprintf("em_call_site/%d", n), func(b *testing.B) {
+			s := MustInitIn[benchInstruments](New(WithMeter(benchMeter())), attrs...)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s.Counter.AddCtx(ctx, 1, callSite)
+			}
+		})
+	}
+}
+
+// BenchmarkRecord is the BenchmarkAdd counterpart for histograms.
+func BenchmarkRecord(b *testing.B) {
+	ctx := context.Background()
+	callSite := metric.WithAttributeSet(attribute.NewSet(attribute.String("call", "site")))
+
+	for _, n := range benchSizes {
+		attrs := benchAttrs(n)
+
+		b.Run(fmt.Sprintf("otel/%d", n), func(b *testing.B) {
+			histogram, err := benchMeter().Float64Histogram("bench_histogram")
+			require.NoError(b, err)
+			opt := metric.WithAttributeSet(attribute.NewSet(attrs...))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				histogram.Record(ctx, 1, opt)
+			}
+		})
+
+		b.Run(fmt.Sprintf("em/%d", n), func(b *testing.B) {
+			s := MustInitIn[benchInstruments](New(WithMeter(benchMeter())), attrs...)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s.Histogram.RecordCtx(ctx, 1)
+			}
+		})
+
+		b.Run(fmt.Sprintf("otel_call_site/%d", n), func(b *testing.B) {
+			histogram, err := benchMeter().Float64Histogram("bench_histogram")
+			require.NoError(b, err)
+			opt := metric.WithAttributeSet(attribute.NewSet(attrs...))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				histogram.Record(ctx, 1, opt, callSite)
+			}
+		})
+
+		b.Run(fmt.Sprintf("em_call_site/%d", n), func(b *testing.B) {
+			s := MustInitIn[benchInstruments](New(WithMeter(benchMeter())), attrs...)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s.Histogram.RecordCtx(ctx, 1, callSite)
+			}
+		})
+	}
+}
+
+// TestRecordAllocs guards the cost of em over OTEL instruments, which must
+// not allocate more than when recording the same attributes directly. It
+// can't run in parallel, as required by testing.AllocsPerRun.
+func TestRecordAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not reliable under the race detector")
+	}
+
+	ctx := context.Background()
+	callSite := metric.WithAttributeSet(attribute.NewSet(attribute.String("call", "site")))
+
+	for _, n := range benchSizes {
+		attrs := benchAttrs(n)
+		opt := metric.WithAttributeSet(attribute.NewSet(attrs...))
+		counter, err := benchMeter().Int64Counter("bench_counter")
+		require.NoError(t, err)
+		histogram, err := benchMeter().Float64Histogram("bench_histogram")
+		require.NoError(t, err)
+		s := MustInitIn[benchInstruments](New(WithMeter(benchMeter())), attrs...)
+
+		allocs := func(fn func()) float64 {
+			fn()
+			return testing.AllocsPerRun(100, fn)
+		}
+
+		require.Zerof(t, allocs(func() {
+			s.Counter.AddCtx(ctx, 1)
+			s.Histogram.RecordCtx(ctx, 1)
+		}), "allocations recording with %d attributes", n)
+
+		otel := allocs(func() {
+			counter.Add(ctx, 1, opt, callSite)
+			histogram.Record(ctx, 1, opt, callSite)
+		})
+		em := allocs(func() {
+			s.Counter.AddCtx(ctx, 1, callSite)
+			s.Histogram.RecordCtx(ctx, 1, callSite)
+		})
+		require.LessOrEqualf(t, em, otel, "allocations recording with %d attributes and call-site options", n)
+
+		observer := &i64Observer{o: noop.Observer{}, inst: noop.Int64ObservableGauge{}, opts: newObserveOptions(attrs)}
+		require.Zerof(t, allocs(func() {
+			observer.Observe(1)
+		}), "allocations observing with %d attributes", n)
+	}
+}
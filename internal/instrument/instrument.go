@@ -0,0 +1,106 @@
+// Package instrument describes the instrument types exported by em, for the
+// tools inspecting instrument structs from source.
+package instrument
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Path is the import path of em.
+const Path = "github.com/ofeefo/em"
+
+// Kinds of instruments, as named by the 'kind' tag.
+const (
+	Counter   = "counter"
+	UpDown    = "updown"
+	Gauge     = "gauge"
+	Histogram = "histogram"
+)
+
+// Info describes an em instrument type.
+type Info struct {
+	// Histogram tells whether the instrument accepts the 'buckets' tag.
+	Histogram bool
+	// Observable tells whether the instrument requires the 'callback' tag.
+	Observable bool
+	// Timer tells whether the instrument only accepts the "s" and "ms" units.
+	Timer bool
+	// Kind is the kind of the instrument.
+	Kind string
+	// Group names the types sharing the method set of the instrument, which
+	// are told apart through the 'kind' tag on types defined on top of them.
+	Group string
+}
+
+// Types maps the names of em instrument types to their description.
+var Types = map[string]Info{
+	"I64Counter":                 {Kind: Counter, Group: "I64Add"},
+	"I64UpDownCounter":           {Kind: UpDown, Group: "I64Add"},
+	"I64Gauge":                   {Kind: Gauge, Group: "I64Record"},
+	"I64Histogram":               {Histogram: true, Kind: Histogram, Group: "I64Record"},
+	"F64Counter":                 {Kind: Counter, Group: "F64Add"},
+	"F64UpDownCounter":           {Kind: UpDown, Group: "F64Add"},
+	"F64Gauge":                   {Kind: Gauge, Group: "F64Record"},
+	"F64Histogram":               {Histogram: true, Kind: Histogram, Group: "F64Record"},
+	"I64ObservableCounter":       {Observable: true, Kind: Counter, Group: "Observable"},
+	"I64ObservableUpDownCounter": {Observable: true, Kind: UpDown, Group: "Observable"},
+	"I64ObservableGauge":         {Observable: true, Kind: Gauge, Group: "Observable"},
+	"F64ObservableCounter":       {Observable: true, Kind: Counter, Group: "Observable"},
+	"F64ObservableUpDownCounter": {Observable: true, Kind: UpDown, Group: "Observable"},
+	"F64ObservableGauge":         {Observable: true, Kind: Gauge, Group: "Observable"},
+	"Timer":                      {Histogram: true, Timer: true, Kind: Histogram, Group: "Timer"},
+}
+
+// kindTags maps the accepted values of the 'kind' tag to the kinds they stand
+// for.
+var kindTags = map[string]string{
+	Counter:   Counter,
+	"c":       Counter,
+	UpDown:    UpDown,
+	"udc":     UpDown,
+	Gauge:     Gauge,
+	"g":       Gauge,
+	Histogram: Histogram,
+	"h":       Histogram,
+}
+
+// Group returns the sorted names of the types sharing the method set of the
+// named type.
+func Group(name string) []string {
+	group := Types[name].Group
+	var names []string
+	for n, info := range Types {
+		if info.Group == group {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the candidate type names matching the kind named by the
+// 'kind' tag value, or every candidate if the tag is empty. Callers report
+// ambiguity when more than one remains.
+func Resolve(candidates []string, tag string) ([]string, error) {
+	if tag == "" {
+		return candidates, nil
+	}
+
+	kind, ok := kindTags[tag]
+	if !ok {
+		return nil, fmt.Errorf("unsupported kind %q, expected counter, updown, gauge or histogram", tag)
+	}
+
+	var res []string
+	for _, c := range candidates {
+		if Types[c].Kind == kind {
+			res = append(res, c)
+		}
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("kind %q does not apply to %s", tag, strings.Join(candidates, " or "))
+	}
+	return res, nil
+}
@@ -0,0 +1,147 @@
+package em
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ofeefo/em/internal/instrument"
+)
+
+// ErrInvalidID is wrapped by the errors of ids that are not valid OTEL
+// instrument names.
+var ErrInvalidID = errors.New("invalid id")
+
+// ValidateID reports whether id is a valid OTEL instrument name: up to 255
+// characters among letters, digits, '_', '.', '-' and '/', starting with a
+// letter.
+func ValidateID(id string) error {
+	if id == "" {
+		return fmt.Errorf("%w %q: empty", ErrInvalidID, id)
+	}
+	if len(id) > 255 {
+		return fmt.Errorf("%w %q: longer than 255 characters", ErrInvalidID, id)
+	}
+	for i, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i == 0:
+			return fmt.Errorf("%w %q: must start with a letter", ErrInvalidID, id)
+		case c >= '0' && c <= '9', c == '_', c == '.', c == '-', c == '/':
+		default:
+			return fmt.Errorf("%w %q: must only contain letters, digits, '_', '.', '-' and '/'", ErrInvalidID, id)
+		}
+	}
+	return nil
+}
+
+// unitSuffixes are appended by the Prometheus exporter to the names of
+// metrics in the given units.
+var unitSuffixes = map[string]string{
+	"d":    "_days",
+	"h":    "_hours",
+	"min":  "_minutes",
+	"s":    "_seconds",
+	"ms":   "_milliseconds",
+	"us":   "_microseconds",
+	"ns":   "_nanoseconds",
+	"By":   "_bytes",
+	"KiBy": "_kibibytes",
+	"MiBy": "_mebibytes",
+	"GiBy": "_gibibytes",
+	"TiBy": "_tibibytes",
+	"KBy":  "_kilobytes",
+	"MBy":  "_megabytes",
+	"GBy":  "_gigabytes",
+	"TBy":  "_terabytes",
+	"m":    "_meters",
+	"V":    "_volts",
+	"A":    "_amperes",
+	"J":    "_joules",
+	"W":    "_watts",
+	"g":    "_grams",
+	"Cel":  "_celsius",
+	"Hz":   "_hertz",
+	"1":    "_ratio",
+	"%":    "_percent",
+}
+
+const counterSuffix = "_total"
+
+// prometheusName returns the name the Prometheus exporter exports the
+// instrument of the named em type with id and unit as, which replaces the
+// characters Prometheus does not support by '_', and appends unit and counter
+// suffixes.
+func prometheusName(id, typeName, unit string) string {
+	name := []byte(id)
+	for i, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' || c >= '0' && c <= '9' && i > 0) {
+			name[i] = '_'
+		}
+	}
+	res := string(name)
+
+	isCounter := instrument.Types[typeName].Kind == instrument.Counter && !instrument.Types[typeName].Histogram
+	if isCounter {
+		res = strings.TrimSuffix(res, counterSuffix)
+	}
+	if suffix, ok := unitSuffixes[unit]; ok && !strings.HasSuffix(res, suffix) {
+		res += suffix
+	}
+	if isCounter {
+		res += counterSuffix
+	}
+	return res
+}
+
+// PrometheusMetric describes the metric an instrument field is exported as by
+// the Prometheus exporter.
+type PrometheusMetric struct {
+	// Path is the dotted path of the field.
+	Path string
+	// ID is the instrument id, including prefixes.
+	ID string
+	// Name is the name of the exported metric. Histograms are exported as
+	// the _bucket, _sum and _count series of this name.
+	Name string
+}
+
+// PrometheusNames is like PrometheusNamesIn, using the package-level
+// registry.
+func PrometheusNames[T any]() ([]PrometheusMetric, error) {
+	return PrometheusNamesIn[T](defaultRegistry)
+}
+
+// PrometheusNamesIn returns the metrics the instruments of T are exported as
+// by the Prometheus exporter of r, without initializing them. It fails as
+// InitIn would on misconfigured fields, and on distinct ids exported under
+// the same name. Namespaces set through exporter options are not included.
+func PrometheusNamesIn[T any](r *Registry) ([]PrometheusMetric, error) {
+	found, st, err := describeRef(r, new(T))
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]PrometheusMetric, 0, len(found))
+	for _, d := range found {
+		metrics = append(metrics, PrometheusMetric{
+			Path: d.path,
+			ID:   d.spec.ID,
+			Name: prometheusName(d.spec.ID, d.typeName, d.spec.Unit),
+		})
+	}
+
+	owners := map[string]PrometheusMetric{}
+	for _, m := range metrics {
+		if prev, ok := owners[m.Name]; ok && prev.ID != m.ID {
+			st.errs.add(m.Path, fmt.Errorf("id %s is exported to Prometheus as %s, as is id %s of field %s", m.ID, m.Name, prev.ID, prev.Path))
+			continue
+		}
+		owners[m.Name] = m
+	}
+
+	if err := st.errs.err(); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
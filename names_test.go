@@ -0,0 +1,159 @@
+package em
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+)
+
+func TestValidateID(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Does accept OTEL instrument names", func(t *testing.T) {
+		for _, id := range []string{"a", "http.server.duration", "db_queries-total/sec", "A1"} {
+			require.NoError(t, ValidateID(id), id)
+		}
+	})
+
+	t.Run("Fails with invalid names", func(t *testing.T) {
+		for id, err := range map[string]string{
+			"":                       "empty",
+			"1st":                    "must start with a letter",
+			"_private":               "must start with a letter",
+			"requests count":         "must only contain",
+			"latency[ms]":            "must only contain",
+			strings.Repeat("a", 256): "longer than 255 characters",
+		} {
+			require.ErrorContains(t, ValidateID(id), err, id)
+			require.ErrorIs(t, ValidateID(id), ErrInvalidID, id)
+		}
+	})
+
+	t.Run("Fails to initialize invalid ids", func(t *testing.T) {
+		type instruments struct {
+			Nested struct {
+				Counter I64Counter `id:"requests"`
+			} `prefix:"http.server "`
+		}
+		_, err := InitIn[instruments](New())
+
+		var fieldErr *FieldError
+		require.ErrorAs(t, err, &fieldErr)
+		require.Equal(t, "Nested.Counter", fieldErr.Path)
+		require.Equal(t, "id", fieldErr.Tag)
+		require.ErrorIs(t, err, ErrInvalidID)
+	})
+}
+
+type exported struct {
+	Requests  I64Counter                 `id:"http.server.requests"`
+	Total     F64Counter                 `id:"bytes_total" unit:"By"`
+	Latency   F64Histogram               `id:"latency" unit:"ms"`
+	Seconds   Timer                      `id:"request_duration_seconds"`
+	InFlight  I64UpDownCounter           `id:"in_flight"`
+	Ratio     F64Gauge                   `id:"cache-hit" unit:"1"`
+	Processed I64ObservableCounter       `id:"processed" callback:"Observe"`
+	PerRoute  Family[string, I64Counter] `id:"routes" key:"route"`
+	Workers   [2]struct {
+		Busy I64Gauge `id:"busy"`
+	} `prefix:"worker_"`
+}
+
+func (e *exported) Observe(_ context.Context, o I64Observer) error {
+	o.Observe(1)
+	return nil
+}
+
+func TestPrometheusNames(t *testing.T) {
+	t.Parallel()
+
+	want := []PrometheusMetric{
+		{Path: "Requests", ID: "http.server.requests", Name: "http_server_requests_total"},
+		{Path: "Total", ID: "bytes_total", Name: "bytes_bytes_total"},
+		{Path: "Latency", ID: "latency", Name: "latency_milliseconds"},
+		{Path: "Seconds", ID: "request_duration_seconds", Name: "request_duration_seconds"},
+		{Path: "InFlight", ID: "in_flight", Name: "in_flight"},
+		{Path: "Ratio", ID: "cache-hit", Name: "cache_hit_ratio"},
+		{Path: "Processed", ID: "processed", Name: "processed_total"},
+		{Path: "PerRoute", ID: "routes", Name: "routes_total"},
+		{Path: "Workers[0].Busy", ID: "worker_busy", Name: "worker_busy"},
+		{Path: "Workers[1].Busy", ID: "worker_busy", Name: "worker_busy"},
+	}
+
+	t.Run("Does return the names of exported metrics", func(t *testing.T) {
+		names, err := PrometheusNamesIn[exported](New())
+		require.NoError(t, err)
+		require.Equal(t, want, names)
+	})
+
+	t.Run("Does match the names exported by the Prometheus exporter", func(t *testing.T) {
+		promReg := promclient.NewRegistry()
+		r := New()
+		require.NoError(t, r.SetupWith("test", WithPrometheus(prometheus.WithRegisterer(promReg), prometheus.WithoutScopeInfo(), prometheus.WithoutTargetInfo())))
+		t.Cleanup(func() { require.NoError(t, r.Shutdown(context.Background())) })
+
+		s := MustInitIn[exported](r)
+		s.Requests.Add(1)
+		s.Total.Add(1)
+		s.Latency.Record(1)
+		s.Seconds.RecordDuration(1)
+		s.InFlight.Add(1)
+		s.Ratio.Record(1)
+		s.PerRoute.Get("/").Add(1)
+		s.Workers[0].Busy.Record(1)
+
+		families, err := promReg.Gather()
+		require.NoError(t, err)
+		var gathered []string
+		for _, f := range families {
+			gathered = append(gathered, f.GetName())
+		}
+
+		names := map[string]bool{}
+		for _, m := range want {
+			names[m.Name] = true
+		}
+		var expected []string
+		for name := range names {
+			expected = append(expected, name)
+		}
+		require.ElementsMatch(t, expected, gathered)
+	})
+
+	t.Run("Does include the registry namespace", func(t *testing.T) {
+		type instruments struct {
+			Requests I64Counter `id:"requests"`
+		}
+		names, err := PrometheusNamesIn[instruments](New(WithNamespace("api.")))
+		require.NoError(t, err)
+		require.Equal(t, []PrometheusMetric{{Path: "Requests", ID: "api.requests", Name: "api_requests_total"}}, names)
+	})
+
+	t.Run("Fails with names colliding after translation", func(t *testing.T) {
+		type instruments struct {
+			Dotted      I64Gauge   `id:"queue.size"`
+			Underscored I64Gauge   `id:"queue_size"`
+			Requests    I64Counter `id:"requests"`
+			Total       I64Counter `id:"requests_total"`
+		}
+		_, err := PrometheusNamesIn[instruments](New())
+
+		var initErr *InitError
+		require.ErrorAs(t, err, &initErr)
+		require.Len(t, initErr.Fields, 2)
+		require.Equal(t, "Underscored", initErr.Fields[0].Path)
+		require.ErrorContains(t, initErr.Fields[0], "id queue_size is exported to Prometheus as queue_size, as is id queue.size of field Dotted")
+		require.Equal(t, "Total", initErr.Fields[1].Path)
+	})
+
+	t.Run("Fails with misconfigured fields", func(t *testing.T) {
+		_, err := PrometheusNamesIn[misconfigured](New())
+		var initErr *InitError
+		require.ErrorAs(t, err, &initErr)
+		require.Len(t, initErr.Fields, 9)
+	})
+}
@@ -0,0 +1,7 @@
+//go:build !race
+
+package em
+
+// raceEnabled tells whether the race detector is on, which makes allocations
+// counts unreliable.
+const raceEnabled = false
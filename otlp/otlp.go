@@ -0,0 +1,198 @@
+// Package otlp configures em to push metrics to an OTLP receiver. It is kept
+// apart from em so that only its users depend on the OTLP exporters.
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	m2 "go.opentelemetry.io/otel/sdk/metric"
+
+	"github.com/ofeefo/em"
+)
+
+const (
+	// ProtocolGRPC exports metrics through OTLP over gRPC.
+	ProtocolGRPC = "grpc"
+	// ProtocolHTTP exports metrics through OTLP over HTTP, encoded as protobuf.
+	ProtocolHTTP = "http/protobuf"
+
+	// CompressionGzip compresses exported payloads with gzip.
+	CompressionGzip = "gzip"
+	// CompressionNone sends exported payloads uncompressed.
+	CompressionNone = "none"
+)
+
+const (
+	protocolEnv        = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	metricsProtocolEnv = "OTEL_EXPORTER_OTLP_METRICS_PROTOCOL"
+)
+
+// Option configures the OTLP exporter added through With.
+type Option func(*config)
+
+type config struct {
+	protocol    string
+	endpoint    string
+	headers     map[string]string
+	compression string
+	insecure    bool
+	temporality m2.TemporalitySelector
+	interval    time.Duration
+}
+
+// With adds a periodic reader pushing metrics to an OTLP receiver to the
+// provider built by em.SetupWith. As with em.WithReader, it replaces the
+// default Prometheus reader unless em.WithPrometheus is also provided.
+//
+// Options not explicitly set are read from the standard OTEL_EXPORTER_OTLP_*
+// environment variables (including OTEL_EXPORTER_OTLP_METRICS_PROTOCOL and
+// OTEL_EXPORTER_OTLP_PROTOCOL) and OTEL_METRIC_EXPORT_INTERVAL.
+func With(opts ...Option) em.SetupOption {
+	cfg := &config{}
+	for _, o := range opts {
+		o(cfg)
+	}
+	return em.WithReaderFunc(cfg.reader)
+}
+
+// Protocol sets the protocol used to export metrics, either
+// ProtocolGRPC or ProtocolHTTP. Defaults to ProtocolHTTP.
+func Protocol(protocol string) Option {
+	return func(c *config) {
+		c.protocol = protocol
+	}
+}
+
+// Endpoint sets the URL of the receiver, such as "http://localhost:4318".
+// Endpoints using the http scheme are reached without TLS.
+func Endpoint(url string) Option {
+	return func(c *config) {
+		c.endpoint = url
+	}
+}
+
+// Headers sets headers sent along with each export.
+func Headers(headers map[string]string) Option {
+	return func(c *config) {
+		c.headers = headers
+	}
+}
+
+// Compression sets the compression of exported payloads, either
+// CompressionGzip or CompressionNone.
+func Compression(compression string) Option {
+	return func(c *config) {
+		c.compression = compression
+	}
+}
+
+// Insecure disables TLS when connecting to the receiver.
+func Insecure() Option {
+	return func(c *config) {
+		c.insecure = true
+	}
+}
+
+// Temporality sets the temporality of exported metrics, such as
+// metric.DeltaTemporalitySelector.
+func Temporality(selector m2.TemporalitySelector) Option {
+	return func(c *config) {
+		c.temporality = selector
+	}
+}
+
+// Interval sets how often metrics are pushed to the receiver.
+func Interval(interval time.Duration) Option {
+	return func(c *config) {
+		c.interval = interval
+	}
+}
+
+func (c *config) reader() (m2.Reader, error) {
+	exp, err := c.exporter()
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []m2.PeriodicReaderOption
+	if c.interval > 0 {
+		opts = append(opts, m2.WithInterval(c.interval))
+	}
+	return m2.NewPeriodicReader(exp, opts...), nil
+}
+
+func (c *config) exporter() (m2.Exporter, error) {
+	protocol := c.protocol
+	if protocol == "" {
+		protocol = envOr(metricsProtocolEnv, envOr(protocolEnv, ProtocolHTTP))
+	}
+
+	if c.compression != "" && c.compression != CompressionGzip && c.compression != CompressionNone {
+		return nil, fmt.Errorf("unsupported OTLP compression: %s", c.compression)
+	}
+
+	switch protocol {
+	case ProtocolGRPC:
+		return otlpmetricgrpc.New(context.Background(), c.grpcOptions()...)
+	case ProtocolHTTP:
+		return otlpmetrichttp.New(context.Background(), c.httpOptions()...)
+	}
+	return nil, fmt.Errorf("unsupported OTLP protocol: %s", protocol)
+}
+
+func (c *config) grpcOptions() []otlpmetricgrpc.Option {
+	var opts []otlpmetricgrpc.Option
+	if c.endpoint != "" {
+		opts = append(opts, otlpmetricgrpc.WithEndpointURL(c.endpoint))
+	}
+	if c.insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+	if c.headers != nil {
+		opts = append(opts, otlpmetricgrpc.WithHeaders(c.headers))
+	}
+	// The gRPC exporter only knows gzip, and sends payloads uncompressed
+	// unless told otherwise.
+	if c.compression == CompressionGzip {
+		opts = append(opts, otlpmetricgrpc.WithCompressor(c.compression))
+	}
+	if c.temporality != nil {
+		opts = append(opts, otlpmetricgrpc.WithTemporalitySelector(c.temporality))
+	}
+	return opts
+}
+
+func (c *config) httpOptions() []otlpmetrichttp.Option {
+	var opts []otlpmetrichttp.Option
+	if c.endpoint != "" {
+		opts = append(opts, otlpmetrichttp.WithEndpointURL(c.endpoint))
+	}
+	if c.insecure {
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	}
+	if c.headers != nil {
+		opts = append(opts, otlpmetrichttp.WithHeaders(c.headers))
+	}
+	switch c.compression {
+	case CompressionGzip:
+		opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+	case CompressionNone:
+		opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.NoCompression))
+	}
+	if c.temporality != nil {
+		opts = append(opts, otlpmetrichttp.WithTemporalitySelector(c.temporality))
+	}
+	return opts
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
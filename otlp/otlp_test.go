@@ -0,0 +1,178 @@
+package otlp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ofeefo/em"
+)
+
+// otlpReceiver is an in-process stand-in for an OTLP collector, keeping the
+// names of received metrics and the headers of the last export.
+type otlpReceiver struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+
+	mu      sync.Mutex
+	names   []string
+	headers map[string]string
+}
+
+func (o *otlpReceiver) record(req *colmetricpb.ExportMetricsServiceRequest, headers map[string]string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.headers = headers
+	for _, rm := range req.GetResourceMetrics() {
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				o.names = append(o.names, m.GetName())
+			}
+		}
+	}
+}
+
+func (o *otlpReceiver) Export(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	headers := map[string]string{}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, v := range md {
+		headers[k] = v[0]
+	}
+	o.record(req, headers)
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+func (o *otlpReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	req := &colmetricpb.ExportMetricsServiceRequest{}
+	if err = proto.Unmarshal(body, req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	headers := map[string]string{}
+	for k := range r.Header {
+		headers[http.CanonicalHeaderKey(k)] = r.Header.Get(k)
+	}
+	o.record(req, headers)
+
+	res, _ := proto.Marshal(&colmetricpb.ExportMetricsServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(res)
+}
+
+func (o *otlpReceiver) received() ([]string, map[string]string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.names, o.headers
+}
+
+func TestOTLP(t *testing.T) {
+	type instruments struct {
+		Counter em.I64Counter `id:"otlp_counter"`
+	}
+
+	export := func(t *testing.T, opts ...Option) {
+		r := em.New()
+		require.NoError(t, r.SetupWith("test", With(opts...)))
+		em.MustInitIn[instruments](r).Counter.Add(1)
+		require.NoError(t, r.Shutdown(context.Background()))
+	}
+
+	t.Run("Exports over HTTP", func(t *testing.T) {
+		receiver := &otlpReceiver{}
+		srv := httptest.NewServer(receiver)
+		defer srv.Close()
+
+		export(t,
+			Protocol(ProtocolHTTP),
+			Endpoint(srv.URL+"/v1/metrics"),
+			Headers(map[string]string{"X-Token": "secret"}),
+		)
+
+		names, headers := receiver.received()
+		require.Contains(t, names, "otlp_counter")
+		require.Equal(t, "secret", headers["X-Token"])
+	})
+
+	t.Run("Exports over gRPC", func(t *testing.T) {
+		receiver := &otlpReceiver{}
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		srv := grpc.NewServer()
+		colmetricpb.RegisterMetricsServiceServer(srv, receiver)
+		go func() { _ = srv.Serve(lis) }()
+		defer srv.Stop()
+
+		export(t,
+			Protocol(ProtocolGRPC),
+			Endpoint("http://"+lis.Addr().String()),
+			Headers(map[string]string{"x-token": "secret"}),
+			Compression(CompressionGzip),
+		)
+
+		names, headers := receiver.received()
+		require.Contains(t, names, "otlp_counter")
+		require.Equal(t, "secret", headers["x-token"])
+	})
+
+	t.Run("Exports uncompressed over gRPC", func(t *testing.T) {
+		var handled []error
+		prev := otel.GetErrorHandler()
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) { handled = append(handled, err) }))
+		t.Cleanup(func() { otel.SetErrorHandler(prev) })
+
+		receiver := &otlpReceiver{}
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		srv := grpc.NewServer()
+		colmetricpb.RegisterMetricsServiceServer(srv, receiver)
+		go func() { _ = srv.Serve(lis) }()
+		defer srv.Stop()
+
+		export(t,
+			Protocol(ProtocolGRPC),
+			Endpoint("http://"+lis.Addr().String()),
+			Compression(CompressionNone),
+		)
+
+		names, _ := receiver.received()
+		require.Contains(t, names, "otlp_counter")
+		require.Empty(t, handled)
+	})
+
+	t.Run("Reads the protocol from the environment", func(t *testing.T) {
+		receiver := &otlpReceiver{}
+		srv := httptest.NewServer(receiver)
+		defer srv.Close()
+
+		t.Setenv(metricsProtocolEnv, ProtocolHTTP)
+		t.Setenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", srv.URL+"/v1/metrics")
+		export(t)
+
+		names, _ := receiver.received()
+		require.Contains(t, names, "otlp_counter")
+	})
+
+	t.Run("Fails with unsupported protocols", func(t *testing.T) {
+		err := em.New().SetupWith("test", With(Protocol("carrier-pigeon")))
+		require.ErrorContains(t, err, "unsupported OTLP protocol")
+	})
+}
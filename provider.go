@@ -1,6 +1,14 @@
 package em
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/metric"
@@ -9,31 +17,436 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
-type provider struct {
-	m metric.Meter
+// Registry owns the meter used to create instruments. Instruments initialized
+// through a registry without a meter are no-ops until a meter is set up, at
+// which point they are bound to it and start recording.
+type Registry struct {
+	mu        sync.RWMutex
+	m         metric.Meter
+	mp        *m2.MeterProvider
+	delegates map[delegate]struct{}
+	// exponential maps the ids of histograms using the base2 exponential
+	// aggregation to their configuration. It is consulted by the view
+	// returned by View, which may run while mu is held.
+	exponential sync.Map
+	// namespace is prepended to the ids of instruments initialized through
+	// InitIn.
+	namespace string
+	// ids maps the ids of the instruments created through the registry to
+	// their first registration, to detect conflicting ones.
+	ids map[string]registration
+	// promNames maps the Prometheus names of the registered ids to them.
+	promNames map[string]string
+	strictIDs bool
+	// promOnly tells whether the provider built by SetupWith only exports to
+	// Prometheus, which drops exponential histograms.
+	promOnly bool
+}
+
+// delegate is implemented by instruments that can be re-bound to the meters
+// their registry holds over time. A nil meter turns them into no-ops.
+type delegate interface {
+	bind(m metric.Meter) error
+}
+
+// Option configures a Registry created through New, or the default one
+// through Configure.
+type Option func(*Registry)
+
+// WithMeter makes the registry create its instruments with the provided meter.
+func WithMeter(meter metric.Meter) Option {
+	return func(r *Registry) {
+		r.m = meter
+	}
+}
+
+// WithNamespace prepends namespace to the ids of the instruments initialized
+// through InitIn, ahead of the 'prefix' tags of nested structs.
+func WithNamespace(namespace string) Option {
+	return func(r *Registry) {
+		r.namespace = namespace
+	}
+}
+
+// New creates a Registry independent of the package-level one used by Setup
+// and Init.
+func New(opts ...Option) *Registry {
+	r := &Registry{}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+// Namespace returns the namespace the registry was created or configured with
+// through WithNamespace.
+func (r *Registry) Namespace() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.namespace
+}
+
+var defaultRegistry = New()
+
+// Default returns the registry used by the package-level functions.
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Configure applies opts to the default registry, which is not created
+// through New. It fails if instruments were already initialized through it.
+func Configure(opts ...Option) error {
+	return defaultRegistry.Configure(opts...)
 }
 
-var prov *provider = nil
+// Configure applies opts to the registry, as New does. Options only apply to
+// instruments initialized afterwards, so it fails if the registry already
+// has instruments.
+func (r *Registry) Configure(opts ...Option) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.ids) > 0 {
+		return errors.New("registry already has instruments: configure it before initializing them")
+	}
+
+	for _, o := range opts {
+		o(r)
+	}
+	return nil
+}
 
 func SetupWithMeter(meter metric.Meter) {
+	defaultRegistry.SetupWithMeter(meter)
+}
+
+// Setup sets up the default registry with a provider exporting to
+// Prometheus. It does nothing if the registry already has a meter.
+func Setup(name string, attrs ...attribute.KeyValue) error {
+	return defaultRegistry.Setup(name, attrs...)
+}
+
+// SetupWith is like Setup, but the provider is built from the given options.
+// Unless a reader is provided through WithReader or WithReaderFunc, a Prometheus
+// reader is used. It fails with ErrAlreadySetup if the registry already has
+// a meter, rather than ignoring the options, and when Prometheus is the only
+// reader while exponential histograms, which it does not export, are
+// initialized.
+func SetupWith(name string, opts ...SetupOption) error {
+	return defaultRegistry.SetupWith(name, opts...)
+}
+
+// Shutdown flushes and releases the provider created by Setup on the default
+// registry.
+func Shutdown(ctx context.Context) error {
+	return defaultRegistry.Shutdown(ctx)
+}
+
+// ForceFlush exports pending measurements of the provider created by Setup on
+// the default registry.
+func ForceFlush(ctx context.Context) error {
+	return defaultRegistry.ForceFlush(ctx)
+}
+
+// SetupWithMeter makes the registry use the provided meter. Instruments
+// already initialized through the registry are bound to it, and failures
+// doing so are reported to the OTEL error handler.
+func (r *Registry) SetupWithMeter(meter metric.Meter) {
 	if meter == nil {
 		return
 	}
-	prov = &provider{m: meter}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.m, r.mp, r.promOnly = meter, nil, false
+	if err := r.bindAll(); err != nil {
+		otel.Handle(err)
+	}
 }
 
-func Setup(name string, attrs ...attribute.KeyValue) error {
-	if prov != nil {
-		return nil
+// ErrAlreadySetup is returned by SetupWith on registries that already have a
+// meter, which must be shut down before being set up again.
+var ErrAlreadySetup = errors.New("registry already set up")
+
+func (r *Registry) Setup(name string, attrs ...attribute.KeyValue) error {
+	if err := r.SetupWith(name, WithAttributes(attrs...)); !errors.Is(err, ErrAlreadySetup) {
+		return err
+	}
+	return nil
+}
+
+func (r *Registry) SetupWith(name string, opts ...SetupOption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.m != nil {
+		return ErrAlreadySetup
+	}
+
+	cfg := &setupConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+
+	promOnly := cfg.prometheusOnly()
+	if ids := r.exponentialIDs(); promOnly && len(ids) > 0 {
+		return errPrometheusExponential(ids...)
+	}
+
+	pOpts, err := cfg.providerOptions()
+	if err != nil {
+		return err
 	}
+	pOpts = append(pOpts, m2.WithView(r.View()))
+
+	exp := m2.NewMeterProvider(pOpts...)
+	r.m = exp.Meter(name)
+	r.mp = exp
+	r.promOnly = promOnly
+	return r.bindAll()
+}
 
-	promEx, err := prometheus.New()
+// Shutdown flushes and releases the provider created by Setup. Afterwards the
+// registry has no meter, so instruments initialized through it are no-ops
+// until it is set up again, when they are re-bound. Meters provided through
+// SetupWithMeter or WithMeter are detached, but their providers are left for
+// the caller to shut down.
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	mp := r.mp
+	r.m, r.mp, r.promOnly = nil, nil, false
+	err := r.bindAll()
+	r.mu.Unlock()
 	if err != nil {
 		return err
 	}
 
-	res := resource.NewWithAttributes(semconv.SchemaURL, attrs...)
-	exp := m2.NewMeterProvider(m2.WithReader(promEx), m2.WithResource(res))
-	prov = &provider{exp.Meter(name)}
+	if mp == nil {
+		return nil
+	}
+	return mp.Shutdown(ctx)
+}
+
+// ForceFlush exports all pending measurements of the provider created by
+// Setup. It does nothing for meters provided by the caller.
+func (r *Registry) ForceFlush(ctx context.Context) error {
+	r.mu.RLock()
+	mp := r.mp
+	r.mu.RUnlock()
+
+	if mp == nil {
+		return nil
+	}
+	return mp.ForceFlush(ctx)
+}
+
+// View returns a view applying the aggregations configured through the
+// 'aggregation' tag of the histograms initialized by the registry. It is
+// installed on the provider built by Setup, and should be provided to
+// providers backing meters given to SetupWithMeter or WithMeter.
+func (r *Registry) View() m2.View {
+	return func(i m2.Instrument) (m2.Stream, bool) {
+		if i.Kind != m2.InstrumentKindHistogram {
+			return m2.Stream{}, false
+		}
+		v, ok := r.exponential.Load(i.Name)
+		if !ok {
+			return m2.Stream{}, false
+		}
+		e := v.(*Exponential)
+		return m2.Stream{
+			Name:        i.Name,
+			Description: i.Description,
+			Unit:        i.Unit,
+			Aggregation: m2.AggregationBase2ExponentialHistogram{
+				MaxSize:  e.MaxSize,
+				MaxScale: e.MaxScale,
+			},
+		}, true
+	}
+}
+
+// setAggregation records the aggregation of the histogram described by spec,
+// before it is bound to any meter. Exponential histograms are refused when the
+// provider built by SetupWith only exports to Prometheus.
+func (r *Registry) setAggregation(spec Spec) error {
+	if spec.Exponential == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.promOnly {
+		return errPrometheusExponential(spec.ID)
+	}
+	r.exponential.Store(spec.ID, spec.Exponential)
 	return nil
 }
+
+// exponentialIDs returns the sorted ids of the histograms using the base2
+// exponential aggregation.
+func (r *Registry) exponentialIDs() []string {
+	var ids []string
+	r.exponential.Range(func(k, _ any) bool {
+		ids = append(ids, k.(string))
+		return true
+	})
+	sort.Strings(ids)
+	return ids
+}
+
+// errPrometheusExponential reports exponential histograms, which the Prometheus
+// exporter does not support and silently drops.
+func errPrometheusExponential(ids ...string) error {
+	return fmt.Errorf("histograms %s use the exponential aggregation, which is not exported to Prometheus: add a reader supporting it through WithReader", strings.Join(ids, ", "))
+}
+
+// SetupOption configures the provider built by SetupWith.
+type SetupOption func(*setupConfig)
+
+type setupConfig struct {
+	readers    []m2.Reader
+	builders   []func() (m2.Reader, error)
+	prometheus []prometheus.Option
+	promReader bool
+	views      []m2.View
+	resource   *resource.Resource
+	attrs      []attribute.KeyValue
+}
+
+// WithReader adds a reader to the provider. Multiple readers may be provided.
+func WithReader(reader m2.Reader) SetupOption {
+	return func(c *setupConfig) {
+		c.readers = append(c.readers, reader)
+	}
+}
+
+// WithReaderFunc adds the reader built by fn when the provider is built,
+// failing SetupWith with its error. It lets packages such as em/otlp build
+// readers from their own options.
+func WithReaderFunc(fn func() (m2.Reader, error)) SetupOption {
+	return func(c *setupConfig) {
+		c.builders = append(c.builders, fn)
+	}
+}
+
+// WithPrometheus adds a Prometheus reader built with the given options. It is
+// only needed to customize the exporter, or to keep it alongside readers
+// provided through WithReader.
+func WithPrometheus(opts ...prometheus.Option) SetupOption {
+	return func(c *setupConfig) {
+		c.promReader = true
+		c.prometheus = append(c.prometheus, opts...)
+	}
+}
+
+// WithView adds views to the provider.
+func WithView(views ...m2.View) SetupOption {
+	return func(c *setupConfig) {
+		c.views = append(c.views, views...)
+	}
+}
+
+// WithResource sets the resource describing the entity producing metrics.
+// Attributes provided through WithAttributes are merged into it.
+func WithResource(res *resource.Resource) SetupOption {
+	return func(c *setupConfig) {
+		c.resource = res
+	}
+}
+
+// WithAttributes adds attributes to the resource of the provider.
+func WithAttributes(attrs ...attribute.KeyValue) SetupOption {
+	return func(c *setupConfig) {
+		c.attrs = append(c.attrs, attrs...)
+	}
+}
+
+// prometheusOnly tells whether the provider only exports to Prometheus.
+func (c *setupConfig) prometheusOnly() bool {
+	if len(c.builders) > 0 {
+		return false
+	}
+	for _, reader := range c.readers {
+		if _, ok := reader.(*prometheus.Exporter); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *setupConfig) providerOptions() ([]m2.Option, error) {
+	res, err := c.buildResource()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []m2.Option{m2.WithResource(res)}
+	if c.promReader || len(c.readers)+len(c.builders) == 0 {
+		promEx, err := prometheus.New(c.prometheus...)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, m2.WithReader(promEx))
+	}
+
+	for _, reader := range c.readers {
+		opts = append(opts, m2.WithReader(reader))
+	}
+
+	for _, build := range c.builders {
+		reader, err := build()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, m2.WithReader(reader))
+	}
+
+	if len(c.views) > 0 {
+		opts = append(opts, m2.WithView(c.views...))
+	}
+	return opts, nil
+}
+
+func (c *setupConfig) buildResource() (*resource.Resource, error) {
+	if c.resource == nil {
+		return resource.NewWithAttributes(semconv.SchemaURL, c.attrs...), nil
+	}
+
+	if len(c.attrs) == 0 {
+		return c.resource, nil
+	}
+	return resource.Merge(c.resource, resource.NewSchemaless(c.attrs...))
+}
+
+// track binds d to the current meter, and keeps it to be re-bound whenever the
+// meter changes.
+func (r *Registry) track(d delegate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.m != nil {
+		if err := d.bind(r.m); err != nil {
+			return err
+		}
+	}
+	if r.delegates == nil {
+		r.delegates = map[delegate]struct{}{}
+	}
+	r.delegates[d] = struct{}{}
+	return nil
+}
+
+// untrack stops re-binding d, once the instruments using it are unregistered
+// or dropped.
+func (r *Registry) untrack(d delegate) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.delegates, d)
+}
+
+// bindAll binds every tracked instrument to the current meter. Callers must
+// hold the registry lock.
+func (r *Registry) bindAll() error {
+	var errs []error
+	for d := range r.delegates {
+		errs = append(errs, d.bind(r.m))
+	}
+	return errors.Join(errs...)
+}
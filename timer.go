@@ -0,0 +1,108 @@
+package em
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type timing interface {
+	Start(opts ...metric.RecordOption) Stop
+	Time(fn func(), opts ...metric.RecordOption)
+	RecordDuration(d time.Duration, opts ...metric.RecordOption)
+	RecordDurationCtx(ctx context.Context, d time.Duration, opts ...metric.RecordOption)
+	Since(t time.Time, opts ...metric.RecordOption)
+	With(attrs ...attribute.KeyValue) Timer
+}
+
+// Timer records durations into a float64 histogram, converted to the unit
+// given by its 'unit' tag: "s" (the default) or "ms".
+type Timer timing
+
+// Stop records the duration elapsed since the Timer.Start call returning it.
+// Options are appended to those given to Start.
+type Stop func(opts ...metric.RecordOption)
+
+// defaultSecondsBuckets are used by timers in seconds without buckets, as the
+// OTEL defaults are meant for milliseconds.
+var defaultSecondsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type timerImpl struct {
+	rec record[float64]
+	// unit is the duration of one unit of the histogram.
+	unit time.Duration
+}
+
+func (t *timerImpl) Start(opts ...metric.RecordOption) Stop {
+	start := time.Now()
+	return func(stopOpts ...metric.RecordOption) {
+		t.Since(start, append(opts[:len(opts):len(opts)], stopOpts...)...)
+	}
+}
+
+func (t *timerImpl) Time(fn func(), opts ...metric.RecordOption) {
+	start := time.Now()
+	defer func() {
+		t.Since(start, opts...)
+	}()
+	fn()
+}
+
+func (t *timerImpl) RecordDuration(d time.Duration, opts ...metric.RecordOption) {
+	t.RecordDurationCtx(context.Background(), d, opts...)
+}
+
+func (t *timerImpl) RecordDurationCtx(ctx context.Context, d time.Duration, opts ...metric.RecordOption) {
+	t.rec.RecordCtx(ctx, float64(d)/float64(t.unit), opts...)
+}
+
+func (t *timerImpl) Since(start time.Time, opts ...metric.RecordOption) {
+	t.RecordDuration(time.Since(start), opts...)
+}
+
+func (t *timerImpl) With(attrs ...attribute.KeyValue) Timer {
+	return &timerImpl{rec: t.rec.With(attrs...), unit: t.unit}
+}
+
+// timerUnit returns the duration of one unit of a timer histogram in unit.
+func timerUnit(unit string) (time.Duration, error) {
+	switch unit {
+	case "", "s":
+		return time.Second, nil
+	case "ms":
+		return time.Millisecond, nil
+	}
+	return 0, fmt.Errorf("unsupported unit %q, expected s or ms", unit)
+}
+
+// timerSpec returns the spec of the histogram of a timer, defaulting to
+// seconds, along with the duration of its unit.
+func timerSpec(spec Spec) (Spec, time.Duration, error) {
+	unit, err := timerUnit(spec.Unit)
+	if err != nil {
+		return spec, 0, fmt.Errorf("%s for timer %s", err, spec.ID)
+	}
+	if unit == time.Second {
+		spec.Unit = "s"
+		if len(spec.Buckets) == 0 && spec.Exponential == nil {
+			spec.Buckets = defaultSecondsBuckets
+		}
+	}
+	return spec, unit, nil
+}
+
+func (r *Registry) timer(spec Spec, attrs ...attribute.KeyValue) (Timer, error) {
+	spec, unit, err := timerSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	rec, err := r.f64r(histogram, spec, attrs...)
+	if err != nil {
+		return nil, err
+	}
+	return &timerImpl{rec: rec, unit: unit}, nil
+}